@@ -0,0 +1,92 @@
+// Package buildinfo reports which build of the service is running.
+//
+// The values are read from the information embedded by the Go toolchain (see runtime/debug),
+// and can be overridden at build time with ldflags, e.g.:
+//
+//	go build -ldflags "-X github.com/tscolari/servicetools/buildinfo.version=v1.2.3"
+//
+// The variables that can be set are: version, revision, time and dirty ("true" or "false").
+package buildinfo
+
+import (
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+)
+
+// Set with ldflags, taking precedence over the information embedded by the Go toolchain.
+var (
+	version  string
+	revision string
+	time     string
+	dirty    string
+)
+
+// Info describes a build.
+type Info struct {
+	// Version is the version of the main module, e.g. "v1.2.3" or "(devel)".
+	Version string `json:"version"`
+
+	// Revision is the VCS revision the build was made from.
+	Revision string `json:"revision"`
+
+	// Time is when the revision was committed, in RFC3339.
+	Time string `json:"time"`
+
+	// Dirty is true if the build had uncommitted changes.
+	Dirty bool `json:"dirty"`
+
+	// GoVersion is the version of Go that made the build.
+	GoVersion string `json:"go_version"`
+}
+
+// Read returns the information of the running build.
+func Read() Info {
+	info := Info{GoVersion: runtime.Version()}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		info.Version = buildInfo.Main.Version
+		info.GoVersion = buildInfo.GoVersion
+
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.Time = setting.Value
+			case "vcs.modified":
+				info.Dirty = setting.Value == "true"
+			}
+		}
+	}
+
+	if version != "" {
+		info.Version = version
+	}
+
+	if revision != "" {
+		info.Revision = revision
+	}
+
+	if time != "" {
+		info.Time = time
+	}
+
+	if modified, err := strconv.ParseBool(dirty); err == nil {
+		info.Dirty = modified
+	}
+
+	return info
+}
+
+// LogValue implements slog.LogValuer, logging the build as a group.
+func (i Info) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("version", i.Version),
+		slog.String("revision", i.Revision),
+		slog.String("time", i.Time),
+		slog.Bool("dirty", i.Dirty),
+		slog.String("go_version", i.GoVersion),
+	)
+}
@@ -0,0 +1,34 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Read(t *testing.T) {
+	t.Run("embedded information", func(t *testing.T) {
+		info := Read()
+		require.Equal(t, runtime.Version(), info.GoVersion)
+	})
+
+	t.Run("ldflags take precedence", func(t *testing.T) {
+		setVar(t, &version, "v1.2.3")
+		setVar(t, &revision, "abc123")
+		setVar(t, &time, "2024-01-02T03:04:05Z")
+		setVar(t, &dirty, "true")
+
+		info := Read()
+		require.Equal(t, "v1.2.3", info.Version)
+		require.Equal(t, "abc123", info.Revision)
+		require.Equal(t, "2024-01-02T03:04:05Z", info.Time)
+		require.True(t, info.Dirty)
+	})
+}
+
+func setVar(t *testing.T, variable *string, value string) {
+	previous := *variable
+	*variable = value
+	t.Cleanup(func() { *variable = previous })
+}
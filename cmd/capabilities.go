@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/pflag"
+
+	"github.com/tscolari/servicetools/database"
+	"github.com/tscolari/servicetools/server"
+)
+
+// Capability describes a component that the server command can discover,
+// configure and manage for a Server.
+// The built-in capabilities (HasDatabase, HasGRPC, etc) are described this way too,
+// and custom ones can be added using WithCustomCapabilities.
+type Capability struct {
+	// Name identifies the capability, e.g. "grpc".
+	Name string
+
+	// Supports reports whether the given Server has this capability,
+	// usually by checking if it implements a Has* interface.
+	Supports func(Server) bool
+
+	// Flags adds the flags used by the capability to the command.
+	// It's optional.
+	Flags func(*pflag.FlagSet)
+
+	// New creates the component, using the values of the flags, and injects it into the Server.
+	New func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, srv Server) (server.Component, error)
+
+	// Prepare loads what the component created by New only needs to be started,
+	// e.g. TLS certificates, so that commands that never start it (describe and
+	// run-task) don't depend on them. It's optional, and only called by the server command.
+	Prepare func(flags *pflag.FlagSet, component server.Component) error
+
+	// Describe lists what the component created by New serves, e.g. its routes,
+	// for the describe command. It's optional.
+	// The describe command creates, but never starts, the components of capabilities
+	// that have Describe, so their New must not connect to external systems.
+	Describe func(component server.Component) []string
+
+	// Reload applies the values of the flags, after the configuration is
+	// reloaded (on SIGHUP), to the component created by New.
+	// It's optional.
+	Reload func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, component server.Component) error
+}
+
+// builtinCapabilities are in dependency order, so that they get stopped
+// in reverse: first the ones receiving traffic, and lastly the databases.
+var builtinCapabilities = []Capability{
+	{
+		Name:     "database",
+		Supports: supports[HasDatabase],
+		Flags: func(flags *pflag.FlagSet) {
+			databaseFlags(flags, "db", "DATABASE", "DB")
+			flags.Bool("migrate-on-start", false, "migrates the database before the server is ready, waiting for other replicas doing the same")
+			flags.String("migrations-path", "./migrations", "path to all migrations, used with --migrate-on-start")
+		},
+		New: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, srv Server) (server.Component, error) {
+			dbConfig, err := databaseConfig(flags, "db")
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate DB configuration: %w", err)
+			}
+
+			withDB, err := server.NewWithDB(ctx, logger, dbConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure DB: %w", err)
+			}
+
+			if migrateOnStart, _ := flags.GetBool("migrate-on-start"); migrateOnStart {
+				migrationsPath, _ := flags.GetString("migrations-path")
+
+				if migrations, ok := srv.(HasMigrations); ok {
+					withDB.MigrateOnStart(migrations.Migrations(), path.Clean(migrationsPath))
+				} else {
+					withDB.MigrateOnStart(os.DirFS(migrationsPath), ".")
+				}
+			}
+
+			srv.(HasDatabase).ConfigureDatabase(withDB)
+			return withDB, nil
+		},
+		Reload: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, component server.Component) error {
+			dbConfig, err := databaseConfig(flags, "db")
+			if err != nil {
+				return fmt.Errorf("failed to generate DB configuration: %w", err)
+			}
+
+			return component.(*server.WithDB).Reconnect(ctx, logger, dbConfig)
+		},
+	},
+	{
+		Name:     "reader-database",
+		Supports: supports[HasReaderDatabase],
+		Flags: func(flags *pflag.FlagSet) {
+			databaseFlags(flags, "reader-db", "DATABASE_READER", "READER DB")
+		},
+		New: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, srv Server) (server.Component, error) {
+			dbConfig, err := databaseConfig(flags, "reader-db")
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate Reader DB configuration: %w", err)
+			}
+
+			withRDB, err := server.NewWithRDB(ctx, logger, dbConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure Reader DB: %w", err)
+			}
+
+			srv.(HasReaderDatabase).ConfigureReaderDatabase(withRDB)
+			return withRDB, nil
+		},
+		Reload: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, component server.Component) error {
+			dbConfig, err := databaseConfig(flags, "reader-db")
+			if err != nil {
+				return fmt.Errorf("failed to generate Reader DB configuration: %w", err)
+			}
+
+			return component.(*server.WithRDB).Reconnect(ctx, logger, dbConfig)
+		},
+	},
+	{
+		Name:     "metrics",
+		Supports: supports[HasMetrics],
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("metrics-address", "localhost:0", "listening address for metrics"+addressFormats)
+			tlsFlags(flags, "metrics", "metrics")
+		},
+		New: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, srv Server) (server.Component, error) {
+			address, _ := flags.GetString("metrics-address")
+			withMetrics := server.NewWithMetrics(address)
+
+			srv.(HasMetrics).ConfigureMetrics(withMetrics)
+			return withMetrics, nil
+		},
+		Prepare: func(flags *pflag.FlagSet, component server.Component) error {
+			return useTLS(flags, "metrics", component.(*server.WithMetrics).UseTLS)
+		},
+		Reload: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, component server.Component) error {
+			return reloadTLS(component.(*server.WithMetrics).TLS())
+		},
+	},
+	{
+		Name:     "worker",
+		Supports: supports[HasWorker],
+		New: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, srv Server) (server.Component, error) {
+			withWorker := server.NewWithWorker()
+			srv.(HasWorker).ConfigureWorker(withWorker)
+			return withWorker, nil
+		},
+		Describe: func(component server.Component) []string {
+			return component.(*server.WithWorker).TaskNames()
+		},
+	},
+	{
+		Name:     "http",
+		Supports: supports[HasHTTP],
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("http-address", "localhost:0", "listening address for HTTP connections"+addressFormats)
+			tlsFlags(flags, "http", "HTTP")
+		},
+		New: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, srv Server) (server.Component, error) {
+			address, _ := flags.GetString("http-address")
+			withHTTP := server.NewWithHTTP(address)
+
+			srv.(HasHTTP).ConfigureHTTP(withHTTP)
+			return withHTTP, nil
+		},
+		Describe: func(component server.Component) []string {
+			return component.(*server.WithHTTP).Patterns()
+		},
+		Prepare: func(flags *pflag.FlagSet, component server.Component) error {
+			return useTLS(flags, "http", component.(*server.WithHTTP).UseTLS)
+		},
+		Reload: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, component server.Component) error {
+			return reloadTLS(component.(*server.WithHTTP).TLS())
+		},
+	},
+	{
+		Name:     "grpc",
+		Supports: supports[HasGRPC],
+		Flags: func(flags *pflag.FlagSet) {
+			flags.String("grpc-address", "localhost:0", "listening address for GRPC connections"+addressFormats)
+			flags.Bool("grpc-on-http", false, "serves GRPC on the HTTP listener (--http-address), instead of on --grpc-address")
+			flags.Float64Slice("grpc-metrics-buckets", prometheus.DefBuckets, "buckets of the GRPC latency histogram, in seconds, with metrics capability")
+			flags.StringSlice("grpc-metrics-exclude", nil, "GRPC methods (/pkg.Service/Method) or services (/pkg.Service/) without metrics, e.g. health checks")
+			tlsFlags(flags, "grpc", "GRPC")
+		},
+		New: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, srv Server) (server.Component, error) {
+			address, _ := flags.GetString("grpc-address")
+			withGRPC := server.NewWithGRPC(address)
+
+			srv.(HasGRPC).ConfigureGRPC(withGRPC)
+			return withGRPC, nil
+		},
+		Describe: func(component server.Component) []string {
+			return describeGRPC(component.(*server.WithGRPC).Services())
+		},
+		Prepare: func(flags *pflag.FlagSet, component server.Component) error {
+			return useTLS(flags, "grpc", component.(*server.WithGRPC).UseTLS)
+		},
+		Reload: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, component server.Component) error {
+			return reloadTLS(component.(*server.WithGRPC).TLS())
+		},
+	},
+}
+
+// capabilitiesFor returns all the capabilities, built-in and then the custom ones,
+// supported by the given server.
+func capabilitiesFor(srv Server, custom []Capability) []Capability {
+	var capabilities []Capability
+
+	for _, c := range append(slices.Clip(builtinCapabilities), custom...) {
+		if c.Supports(srv) {
+			capabilities = append(capabilities, c)
+		}
+	}
+
+	return capabilities
+}
+
+// errNoDatabaseConfig is returned when a database capability has no configuration.
+var errNoDatabaseConfig = errors.New("no database configuration given")
+
+// databaseFlags adds the flags that configure a database connection, named after the given prefix.
+// Each flag is also backed by an env variable, named after the value of the
+// `<prefix>-env-prefix` flag (see database.ConfigFromEnv).
+func databaseFlags(flags *pflag.FlagSet, prefix, defaultEnvPrefix, description string) {
+	flags.String(prefix+"-env-prefix", defaultEnvPrefix, fmt.Sprintf("prefix to env variables with %s configuration", description))
+
+	flags.String(prefix+"-hostname", "", description+" hostname")
+	flags.Int(prefix+"-port", 5432, description+" port")
+	flags.String(prefix+"-username", "", description+" username")
+	flags.String(prefix+"-password", "", description+" password")
+	flags.String(prefix+"-name", "", description+" name")
+	flags.Var(new(sslModeValue), prefix+"-sslmode", "enables SSL for the "+description+" connection, with \"true\"")
+	flags.Lookup(prefix + "-sslmode").NoOptDefVal = "true"
+	flags.Int(prefix+"-max-idle-conns", 0, "maximum number of idle "+description+" connections")
+	flags.Int(prefix+"-max-open-conns", 0, "maximum number of open "+description+" connections")
+	flags.Duration(prefix+"-conn-max-idle-time", 0, "maximum amount of time a "+description+" connection may be idle")
+	flags.Duration(prefix+"-conn-max-life-time", 0, "maximum amount of time a "+description+" connection may be reused")
+	flags.Duration(prefix+"-connect-timeout", 30*time.Second, "how long to keep retrying to connect to the "+description+" on start, 0 to try only once")
+	flags.Duration(prefix+"-connect-retry-interval", 500*time.Millisecond, "initial wait between attempts to connect to the "+description+", doubled on every attempt")
+	flags.Duration(prefix+"-connect-retry-max-interval", 10*time.Second, "maximum wait between attempts to connect to the "+description)
+	flags.Bool(prefix+"-trace-queries", false, "creates spans for the "+description+" queries made with a context holding a span")
+
+	markSecret(flags, prefix+"-password")
+
+	for flag, env := range map[string]string{
+		"hostname":           "HOSTNAME",
+		"port":               "PORT",
+		"username":           "USERNAME",
+		"password":           "PASSWORD",
+		"name":               "NAME",
+		"sslmode":            "SSLMODE",
+		"max-idle-conns":     "MAX_IDLE_CONNS",
+		"max-open-conns":     "MAX_OPEN_CONNS",
+		"conn-max-idle-time": "CONN_MAX_IDLE_TIME",
+		"conn-max-life-time": "CONN_MAX_LIFE_TIME",
+
+		"connect-timeout":            "CONNECT_TIMEOUT",
+		"connect-retry-interval":     "CONNECT_RETRY_INTERVAL",
+		"connect-retry-max-interval": "CONNECT_RETRY_MAX_INTERVAL",
+
+		"trace-queries": "TRACE_QUERIES",
+	} {
+		bindEnv(flags, prefix+"-"+flag, fmt.Sprintf("${%s-env-prefix}_%s", prefix, env))
+	}
+}
+
+// sslModeValue is a boolean flag value that, like database.ConfigFromEnv, treats any
+// value other than "true" as false, so that env variables set to Postgres SSL modes,
+// e.g. "disable", keep working.
+type sslModeValue bool
+
+func (v *sslModeValue) Set(value string) error {
+	*v = value == "true"
+	return nil
+}
+
+func (v *sslModeValue) String() string {
+	return strconv.FormatBool(bool(*v))
+}
+
+func (v *sslModeValue) Type() string {
+	return "bool"
+}
+
+// databaseConfig builds the database configuration from the flags named after the given prefix.
+func databaseConfig(flags *pflag.FlagSet, prefix string) (*database.Config, error) {
+	var config database.Config
+
+	config.Hostname, _ = flags.GetString(prefix + "-hostname")
+	if config.Hostname == "" {
+		return nil, errNoDatabaseConfig
+	}
+
+	config.Port, _ = flags.GetInt(prefix + "-port")
+	config.Username, _ = flags.GetString(prefix + "-username")
+	config.Password, _ = flags.GetString(prefix + "-password")
+	config.DBName, _ = flags.GetString(prefix + "-name")
+	config.SSLMode, _ = flags.GetBool(prefix + "-sslmode")
+	config.MaxIdleConns, _ = flags.GetInt(prefix + "-max-idle-conns")
+	config.MaxOpenConns, _ = flags.GetInt(prefix + "-max-open-conns")
+	config.ConnMaxIdleTime, _ = flags.GetDuration(prefix + "-conn-max-idle-time")
+	config.ConnMaxLifeTime, _ = flags.GetDuration(prefix + "-conn-max-life-time")
+	config.ConnectTimeout, _ = flags.GetDuration(prefix + "-connect-timeout")
+	config.ConnectRetryInterval, _ = flags.GetDuration(prefix + "-connect-retry-interval")
+	config.ConnectRetryMaxInterval, _ = flags.GetDuration(prefix + "-connect-retry-max-interval")
+	config.TraceQueries, _ = flags.GetBool(prefix + "-trace-queries")
+
+	return &config, nil
+}
+
+// addressFormats lists the formats of the listening addresses, for the flag usages.
+const addressFormats = ": host:port, unix:///path.sock, fd://<n> or systemd://<name>"
+
+// tlsFlags adds the flags that configure TLS for a listener, named after the given prefix.
+func tlsFlags(flags *pflag.FlagSet, prefix, description string) {
+	flags.String(prefix+"-tls-cert", "", "path to the TLS certificate of the "+description+" server, enables TLS")
+	flags.String(prefix+"-tls-key", "", "path to the TLS key of the "+description+" server")
+	flags.String(prefix+"-tls-client-ca", "", "path to the CA of the "+description+" clients, enables mutual TLS")
+}
+
+// tlsCertificates loads the certificates from the TLS flags named after the given prefix.
+// It returns nil if TLS is not enabled.
+func tlsCertificates(flags *pflag.FlagSet, prefix string) (*server.TLSCertificates, error) {
+	certFile, _ := flags.GetString(prefix + "-tls-cert")
+	keyFile, _ := flags.GetString(prefix + "-tls-key")
+	clientCAFile, _ := flags.GetString(prefix + "-tls-client-ca")
+
+	if certFile == "" && keyFile == "" && clientCAFile == "" {
+		return nil, nil
+	}
+
+	certificates, err := server.NewTLSCertificates(certFile, keyFile, clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure TLS for %s: %w", prefix, err)
+	}
+
+	return certificates, nil
+}
+
+// useTLS gives the certificates from the TLS flags named after the given prefix
+// to the listener, with the given use function, if TLS is enabled.
+func useTLS(flags *pflag.FlagSet, prefix string, use func(*server.TLSCertificates)) error {
+	certificates, err := tlsCertificates(flags, prefix)
+	if err != nil {
+		return err
+	}
+
+	if certificates != nil {
+		use(certificates)
+	}
+
+	return nil
+}
+
+// reloadTLS reloads the given certificates, if TLS is enabled.
+// The paths of the files can't be changed without a restart.
+func reloadTLS(certificates *server.TLSCertificates) error {
+	if certificates == nil {
+		return nil
+	}
+
+	return certificates.Reload()
+}
+
+// supports returns true if srv implements T.
+func supports[T any](srv Server) bool {
+	_, ok := srv.(T)
+	return ok
+}
@@ -0,0 +1,402 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// configFlag is the flag holding the path to the configuration file.
+	configFlag = "config"
+
+	// envAnnotation is the flag annotation listing the env variables that back a flag.
+	// Env variable names can reference the value of other flags, e.g. "${db-env-prefix}_HOSTNAME".
+	envAnnotation = "servicetools_env"
+
+	// secretAnnotation marks flags whose values must never be printed.
+	secretAnnotation = "servicetools_secret"
+
+	// sourceAnnotation records where the current value of a flag came from.
+	sourceAnnotation = "servicetools_source"
+
+	// defaultAnnotation keeps the default items of slice flags, as their
+	// DefValue, e.g. "[0.100000,1.000000]", can't be parsed back.
+	defaultAnnotation = "servicetools_default"
+
+	redacted = "REDACTED"
+)
+
+// Sources of flag values, in order of precedence.
+const (
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceFile    = "file"
+	sourceDefault = "default"
+)
+
+// bindEnv sets the env variables that back the given flag, in order of precedence.
+func bindEnv(flags *pflag.FlagSet, name string, envVars ...string) {
+	_ = flags.SetAnnotation(name, envAnnotation, envVars)
+}
+
+// markSecret marks the given flag as holding a secret, so that its value is never printed.
+func markSecret(flags *pflag.FlagSet, name string) {
+	_ = flags.SetAnnotation(name, secretAnnotation, []string{"true"})
+}
+
+// bindEnvPrefix backs every flag that isn't bound to env variables yet with one
+// named after the given prefix and the flag, e.g. "SERVICE_GRPC_ADDRESS" for `grpc-address`.
+// The env variables are also added to the usage of the flags, so that they are shown in the help.
+func bindEnvPrefix(flags *pflag.FlagSet, prefix string) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" {
+			return
+		}
+
+		if len(f.Annotations[envAnnotation]) == 0 {
+			bindEnv(flags, f.Name, envName(prefix, f.Name))
+		}
+
+		envVars := make([]string, len(f.Annotations[envAnnotation]))
+		for i, envVar := range f.Annotations[envAnnotation] {
+			// References to other flags are shown with their default values.
+			envVars[i] = os.Expand(envVar, func(name string) string {
+				if ref := flags.Lookup(name); ref != nil {
+					return ref.DefValue
+				}
+				return ""
+			})
+		}
+
+		f.Usage = fmt.Sprintf("%s (env: %s)", f.Usage, strings.Join(envVars, ", "))
+	})
+}
+
+// envName returns the env variable name for the given flag, e.g. "SERVICE_GRPC_ADDRESS".
+func envName(prefix, flagName string) string {
+	name := strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "_" + name
+}
+
+// configFlags adds the flag used to give a configuration file.
+func configFlags(flags *pflag.FlagSet) {
+	flags.String(configFlag, "", "path to a YAML or JSON configuration file")
+}
+
+// loadConfig sets every flag that wasn't given in the command line using the
+// environment or the configuration file (from the --config flag), in that order.
+// This gives the precedence: flags > env > file > defaults.
+// It can be called again to reload the values from the environment and the file.
+func loadConfig(flags *pflag.FlagSet) error {
+	fileValues := map[string]string{}
+
+	// The configuration file itself can only be given by a flag or env variable.
+	if f := flags.Lookup(configFlag); f != nil {
+		if err := resolveFlag(flags, f, fileValues); err != nil {
+			return err
+		}
+	}
+
+	if path, _ := flags.GetString(configFlag); path != "" {
+		var err error
+		fileValues, err = readConfigFile(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	var unknownKeys []string
+	for name := range fileValues {
+		if flags.Lookup(name) == nil {
+			unknownKeys = append(unknownKeys, name)
+		}
+	}
+
+	if len(unknownKeys) > 0 {
+		sort.Strings(unknownKeys)
+		return fmt.Errorf("unknown configuration keys: %s", strings.Join(unknownKeys, ", "))
+	}
+
+	// Flags whose env variables reference other flags are resolved last,
+	// so that the values they reference are already resolved.
+	var deferred []*pflag.Flag
+	var errs []error
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == configFlag {
+			return
+		}
+
+		for _, envVar := range f.Annotations[envAnnotation] {
+			if strings.Contains(envVar, "$") {
+				deferred = append(deferred, f)
+				return
+			}
+		}
+
+		errs = append(errs, resolveFlag(flags, f, fileValues))
+	})
+
+	for _, f := range deferred {
+		errs = append(errs, resolveFlag(flags, f, fileValues))
+	}
+
+	return errors.Join(errs...)
+}
+
+// resolveFlag sets the value of a flag, unless it was given in the command line.
+// It returns an error if the flag was given in the command line, but one of its
+// env variables is set to a different value.
+func resolveFlag(flags *pflag.FlagSet, f *pflag.Flag, fileValues map[string]string) error {
+	source := flagSource(f)
+
+	for _, envVar := range f.Annotations[envAnnotation] {
+		envVar = os.Expand(envVar, func(name string) string {
+			if ref := flags.Lookup(name); ref != nil {
+				return ref.Value.String()
+			}
+			return ""
+		})
+
+		value, ok := os.LookupEnv(envVar)
+		if !ok {
+			continue
+		}
+
+		if source != sourceFlag {
+			return setFlag(flags, f, value, sourceEnv, "env variable "+envVar)
+		}
+
+		if !sameValue(f, value) {
+			return fmt.Errorf("flag --%s is set to %q, but env variable %s is set to %q", f.Name, f.Value.String(), envVar, value)
+		}
+	}
+
+	if source == sourceFlag {
+		return nil
+	}
+
+	if value, ok := fileValues[f.Name]; ok {
+		return setFlag(flags, f, value, sourceFile, "configuration file")
+	}
+
+	// Values previously loaded from env or file are reverted, as they are no longer set.
+	if source != sourceDefault {
+		return restoreDefault(flags, f)
+	}
+
+	return nil
+}
+
+// restoreDefault reverts the flag to its default value.
+func restoreDefault(flags *pflag.FlagSet, f *pflag.Flag) error {
+	slice, ok := f.Value.(pflag.SliceValue)
+	if !ok {
+		return setFlag(flags, f, f.DefValue, sourceDefault, "default value")
+	}
+
+	if err := slice.Replace(f.Annotations[defaultAnnotation]); err != nil {
+		return fmt.Errorf("invalid value for %q from default value: %w", f.Name, err)
+	}
+
+	return flags.SetAnnotation(f.Name, sourceAnnotation, []string{sourceDefault})
+}
+
+// flagSource returns where the current value of the flag came from.
+func flagSource(f *pflag.Flag) string {
+	if source := f.Annotations[sourceAnnotation]; len(source) > 0 {
+		return source[0]
+	}
+
+	if f.Changed {
+		return sourceFlag
+	}
+
+	return sourceDefault
+}
+
+// sameValue returns true if the given value, once parsed, is the same as the
+// current value of the flag, e.g. "1m" and "60s" for durations.
+func sameValue(f *pflag.Flag, value string) bool {
+	current := f.Value.String()
+	if value == current {
+		return true
+	}
+
+	// The value is parsed by the flag itself, and its current value restored.
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		items := slice.GetSlice()
+		defer func() { _ = slice.Replace(items) }()
+
+		return slice.Replace(splitItems(value)) == nil && f.Value.String() == current
+	}
+
+	defer func() { _ = f.Value.Set(current) }()
+
+	return f.Value.Set(value) == nil && f.Value.String() == current
+}
+
+func setFlag(flags *pflag.FlagSet, f *pflag.Flag, value, source, description string) error {
+	var err error
+
+	// Setting a slice flag that was already set appends to it, so it's replaced instead.
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		if _, saved := f.Annotations[defaultAnnotation]; !saved {
+			_ = flags.SetAnnotation(f.Name, defaultAnnotation, slice.GetSlice())
+		}
+
+		err = slice.Replace(splitItems(value))
+		f.Changed = true
+	} else {
+		err = flags.Set(f.Name, value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid value for %q from %s: %w", f.Name, description, err)
+	}
+
+	return flags.SetAnnotation(f.Name, sourceAnnotation, []string{source})
+}
+
+// splitItems splits the comma-separated value of a slice flag.
+func splitItems(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	return strings.Split(value, ",")
+}
+
+// readConfigFile reads a YAML or JSON configuration file into a map of flag names and values.
+// Nested keys are joined with "-", so that `grpc: {address: ":8080"}` sets the `grpc-address` flag.
+func readConfigFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	// JSON is valid YAML, so the same parser is used for both.
+	var config map[string]any
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file: %w", err)
+	}
+
+	values := map[string]string{}
+	flattenConfig("", config, values)
+
+	return values, nil
+}
+
+func flattenConfig(prefix string, config map[string]any, values map[string]string) {
+	for key, value := range config {
+		key = strings.ReplaceAll(strings.ToLower(key), "_", "-")
+		if prefix != "" {
+			key = prefix + "-" + key
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			flattenConfig(key, v, values)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// newConfigCommand returns the command that groups subcommands related to the
+// configuration of a server command.
+// The flags of the server command must be added to, or inherited by, it.
+func newConfigCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspects the server configuration",
+	}
+
+	// print shows the effective configuration of the server command,
+	// after applying flags, env variables, the configuration file and defaults.
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "print",
+		Short: "Prints the effective server configuration, with secrets redacted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(cmd.Flags()); err != nil {
+				return err
+			}
+
+			return printConfig(cmd.OutOrStdout(), cmd.Flags())
+		},
+	})
+
+	return configCmd
+}
+
+// printConfig writes the flags of the given set as a YAML configuration file,
+// annotating where each value came from.
+func printConfig(out io.Writer, flags *pflag.FlagSet) error {
+	config := &yaml.Node{Kind: yaml.MappingNode}
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == configFlag || f.Name == "help" {
+			return
+		}
+
+		value := &yaml.Node{
+			Kind:        yaml.ScalarNode,
+			Tag:         yamlTag(f.Value.Type()),
+			Value:       f.Value.String(),
+			LineComment: flagSource(f),
+		}
+
+		if len(f.Annotations[secretAnnotation]) > 0 && value.Value != "" {
+			value.Tag = "!!str"
+			value.Value = redacted
+		}
+
+		config.Content = append(config.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: f.Name},
+			value,
+		)
+	})
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(config); err != nil {
+		return fmt.Errorf("failed to print configuration: %w", err)
+	}
+
+	return encoder.Close()
+}
+
+func yamlTag(flagType string) string {
+	switch flagType {
+	case "bool":
+		return "!!bool"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return "!!int"
+	case "float32", "float64":
+		return "!!float"
+	}
+
+	return "!!str"
+}
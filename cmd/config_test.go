@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfigFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	configFlags(flags)
+	flags.String("grpc-address", "localhost:0", "")
+	databaseFlags(flags, "db", "TEST_DATABASE", "DB")
+
+	require.NoError(t, flags.Parse(args))
+	return flags
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func Test_LoadConfig(t *testing.T) {
+	t.Run("precedence", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", `
+grpc:
+  address: "file:1"
+db:
+  hostname: file-host
+  port: 1111
+  max_open_conns: 10
+`)
+
+		t.Setenv("TEST_DATABASE_PORT", "2222")
+
+		flags := testConfigFlags(t, "--config", path, "--db-hostname", "flag-host")
+		require.NoError(t, loadConfig(flags))
+
+		config, err := databaseConfig(flags, "db")
+		require.NoError(t, err)
+		require.Equal(t, "flag-host", config.Hostname)
+		require.Equal(t, 2222, config.Port)
+		require.Equal(t, 10, config.MaxOpenConns)
+		require.Zero(t, config.MaxIdleConns)
+
+		address, _ := flags.GetString("grpc-address")
+		require.Equal(t, "file:1", address)
+	})
+
+	t.Run("tracing database queries", func(t *testing.T) {
+		flags := testConfigFlags(t, "--db-hostname", "localhost")
+		require.NoError(t, loadConfig(flags))
+
+		config, err := databaseConfig(flags, "db")
+		require.NoError(t, err)
+		require.False(t, config.TraceQueries)
+
+		t.Setenv("TEST_DATABASE_TRACE_QUERIES", "true")
+
+		flags = testConfigFlags(t, "--db-hostname", "localhost")
+		require.NoError(t, loadConfig(flags))
+
+		config, err = databaseConfig(flags, "db")
+		require.NoError(t, err)
+		require.True(t, config.TraceQueries)
+	})
+
+	t.Run("json files", func(t *testing.T) {
+		path := writeConfigFile(t, "config.json", `{"grpc-address": "json:1", "db": {"conn-max-life-time": "1m"}}`)
+
+		flags := testConfigFlags(t, "--config", path)
+		require.NoError(t, loadConfig(flags))
+
+		address, _ := flags.GetString("grpc-address")
+		require.Equal(t, "json:1", address)
+
+		lifeTime, _ := flags.GetDuration("db-conn-max-life-time")
+		require.Equal(t, "1m0s", lifeTime.String())
+	})
+
+	t.Run("env prefix given in the file", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "db-env-prefix: OTHER_DATABASE\n")
+		t.Setenv("OTHER_DATABASE_HOSTNAME", "other-host")
+
+		flags := testConfigFlags(t, "--config", path)
+		require.NoError(t, loadConfig(flags))
+
+		hostname, _ := flags.GetString("db-hostname")
+		require.Equal(t, "other-host", hostname)
+	})
+
+	t.Run("reloading reverts values no longer set", func(t *testing.T) {
+		t.Setenv("TEST_DATABASE_HOSTNAME", "env-host")
+
+		flags := testConfigFlags(t)
+		require.NoError(t, loadConfig(flags))
+
+		hostname, _ := flags.GetString("db-hostname")
+		require.Equal(t, "env-host", hostname)
+
+		require.NoError(t, os.Unsetenv("TEST_DATABASE_HOSTNAME"))
+		require.NoError(t, loadConfig(flags))
+
+		hostname, _ = flags.GetString("db-hostname")
+		require.Empty(t, hostname)
+	})
+
+	t.Run("reloading reverts slice values no longer set", func(t *testing.T) {
+		t.Setenv("TEST_BUCKETS", "0.5")
+
+		flags := testConfigFlags(t)
+		flags.Float64Slice("buckets", []float64{0.1, 1}, "")
+		bindEnv(flags, "buckets", "TEST_BUCKETS")
+		require.NoError(t, loadConfig(flags))
+
+		buckets, _ := flags.GetFloat64Slice("buckets")
+		require.Equal(t, []float64{0.5}, buckets)
+
+		require.NoError(t, os.Unsetenv("TEST_BUCKETS"))
+		require.NoError(t, loadConfig(flags))
+
+		buckets, _ = flags.GetFloat64Slice("buckets")
+		require.Equal(t, []float64{0.1, 1}, buckets)
+	})
+
+	t.Run("flag and env set to different values", func(t *testing.T) {
+		t.Setenv("TEST_DATABASE_HOSTNAME", "env-host")
+
+		flags := testConfigFlags(t, "--db-hostname", "flag-host")
+		require.ErrorContains(t, loadConfig(flags), `flag --db-hostname is set to "flag-host", but env variable TEST_DATABASE_HOSTNAME is set to "env-host"`)
+	})
+
+	t.Run("flag and env set to the same value", func(t *testing.T) {
+		t.Setenv("TEST_DATABASE_CONNECT_TIMEOUT", "1m")
+
+		flags := testConfigFlags(t, "--db-connect-timeout", "60s")
+		require.NoError(t, loadConfig(flags))
+
+		timeout, _ := flags.GetDuration("db-connect-timeout")
+		require.Equal(t, time.Minute, timeout)
+	})
+
+	t.Run("database SSL mode set to a Postgres mode", func(t *testing.T) {
+		t.Setenv("DATABASE_HOSTNAME", "localhost")
+		t.Setenv("DATABASE_SSLMODE", "disable")
+
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		databaseFlags(flags, "db", "DATABASE", "DB")
+		require.NoError(t, loadConfig(flags))
+
+		config, err := databaseConfig(flags, "db")
+		require.NoError(t, err)
+		require.False(t, config.SSLMode)
+
+		t.Setenv("DATABASE_SSLMODE", "true")
+		require.NoError(t, loadConfig(flags))
+
+		config, err = databaseConfig(flags, "db")
+		require.NoError(t, err)
+		require.True(t, config.SSLMode)
+
+		require.NoError(t, flags.Parse([]string{"--db-sslmode"}))
+		sslMode, err := flags.GetBool("db-sslmode")
+		require.NoError(t, err)
+		require.True(t, sslMode)
+	})
+
+	t.Run("unknown keys", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "grpc:\n  adress: localhost:1\n")
+
+		flags := testConfigFlags(t, "--config", path)
+		require.ErrorContains(t, loadConfig(flags), "unknown configuration keys: grpc-adress")
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "db-port: not-a-number\n")
+
+		flags := testConfigFlags(t, "--config", path)
+		require.ErrorContains(t, loadConfig(flags), `invalid value for "db-port" from configuration file`)
+	})
+}
+
+func Test_BindEnvPrefix(t *testing.T) {
+	t.Run("every flag is bound", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "db-port: 1111\n")
+		t.Setenv("TEST_CONFIG", path)
+		t.Setenv("TEST_GRPC_ADDRESS", "env:1")
+
+		flags := testConfigFlags(t)
+		bindEnvPrefix(flags, "TEST")
+		require.NoError(t, loadConfig(flags))
+
+		address, _ := flags.GetString("grpc-address")
+		require.Equal(t, "env:1", address)
+
+		port, _ := flags.GetInt("db-port")
+		require.Equal(t, 1111, port)
+	})
+
+	t.Run("help shows the env variables", func(t *testing.T) {
+		flags := testConfigFlags(t)
+		bindEnvPrefix(flags, "TEST")
+
+		require.Contains(t, flags.Lookup("grpc-address").Usage, "(env: TEST_GRPC_ADDRESS)")
+		require.Contains(t, flags.Lookup("db-hostname").Usage, "(env: TEST_DATABASE_HOSTNAME)")
+		require.Contains(t, flags.Lookup("db-env-prefix").Usage, "(env: TEST_DB_ENV_PREFIX)")
+	})
+
+	t.Run("no prefix", func(t *testing.T) {
+		require.Equal(t, "GRPC_ADDRESS", envName("", "grpc-address"))
+	})
+}
+
+func Test_PrintConfig(t *testing.T) {
+	t.Setenv("TEST_DATABASE_PASSWORD", "secret")
+
+	flags := testConfigFlags(t, "--grpc-address", "localhost:8080")
+	require.NoError(t, loadConfig(flags))
+
+	out := &bytes.Buffer{}
+	require.NoError(t, printConfig(out, flags))
+
+	require.NotContains(t, out.String(), "secret")
+	require.Contains(t, out.String(), "db-password: REDACTED # env\n")
+	require.Contains(t, out.String(), "grpc-address: localhost:8080 # flag\n")
+	require.Contains(t, out.String(), "db-port: 5432 # default\n")
+	require.NotContains(t, out.String(), "config:")
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+// serverDescription is what the describe command prints.
+type serverDescription struct {
+	// Capabilities lists the names of the capabilities of the server, in order.
+	Capabilities []string `json:"capabilities"`
+
+	// Components lists, by capability name, what each component serves.
+	Components map[string][]string `json:"components"`
+}
+
+// newDescribeCommand returns the command that prints the API surface of the given
+// server: its capabilities, gRPC services and methods, HTTP patterns and worker tasks,
+// without starting it.
+// The flags of the server command must be added to, or inherited by, it.
+func newDescribeCommand(srv Server, capabilities func() []Capability) *cobra.Command {
+	var output string
+
+	describeCmd := &cobra.Command{
+		Use:     "describe",
+		Aliases: []string{"routes"},
+		Short:   "Describes the services, routes and tasks of the server, without starting it",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid output format %q", output)
+			}
+
+			if err := loadConfig(cmd.Flags()); err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			// Components are never started, so there's nothing worth logging.
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			description := serverDescription{Components: map[string][]string{}}
+
+			for _, capability := range capabilities() {
+				description.Capabilities = append(description.Capabilities, capability.Name)
+
+				if capability.Describe == nil {
+					continue
+				}
+
+				component, err := capability.New(cmd.Context(), logger, cmd.Flags(), srv)
+				if err != nil {
+					return fmt.Errorf("failed to configure %s: %w", capability.Name, err)
+				}
+
+				// Sorted, so that descriptions can be diffed.
+				items := capability.Describe(component)
+				sort.Strings(items)
+				description.Components[capability.Name] = items
+			}
+
+			if output == "json" {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(description)
+			}
+
+			printDescription(cmd.OutOrStdout(), description)
+			return nil
+		},
+	}
+
+	describeCmd.Flags().StringVarP(&output, "output", "o", "text", "output format: text or json")
+
+	return describeCmd
+}
+
+func printDescription(out io.Writer, description serverDescription) {
+	fmt.Fprintln(out, "capabilities:")
+	for _, name := range description.Capabilities {
+		fmt.Fprintf(out, "  %s\n", name)
+	}
+
+	for _, name := range description.Capabilities {
+		items, ok := description.Components[name]
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(out, "%s:\n", name)
+		for _, item := range items {
+			fmt.Fprintf(out, "  %s\n", item)
+		}
+	}
+}
+
+// describeGRPC lists every method of the given services as "<service>/<method> (<type>)".
+func describeGRPC(services map[string]grpc.ServiceInfo) []string {
+	methods := []string{}
+
+	for serviceName, info := range services {
+		for _, method := range info.Methods {
+			methodType := "unary"
+			switch {
+			case method.IsClientStream && method.IsServerStream:
+				methodType = "bidi-streaming"
+			case method.IsClientStream:
+				methodType = "client-streaming"
+			case method.IsServerStream:
+				methodType = "server-streaming"
+			}
+
+			methods = append(methods, fmt.Sprintf("%s/%s (%s)", serviceName, method.Name, methodType))
+		}
+	}
+
+	return methods
+}
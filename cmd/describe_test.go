@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func Test_Describe(t *testing.T) {
+	t.Run("gRPC methods", func(t *testing.T) {
+		methods := describeGRPC(map[string]grpc.ServiceInfo{
+			"test.Service": {
+				Methods: []grpc.MethodInfo{
+					{Name: "Get"},
+					{Name: "Watch", IsServerStream: true},
+					{Name: "Upload", IsClientStream: true},
+					{Name: "Chat", IsClientStream: true, IsServerStream: true},
+				},
+			},
+		})
+
+		require.ElementsMatch(t, []string{
+			"test.Service/Get (unary)",
+			"test.Service/Watch (server-streaming)",
+			"test.Service/Upload (client-streaming)",
+			"test.Service/Chat (bidi-streaming)",
+		}, methods)
+	})
+
+	t.Run("text output", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		printDescription(out, serverDescription{
+			Capabilities: []string{"metrics", "http"},
+			Components: map[string][]string{
+				"http": {"/bye", "/hello"},
+			},
+		})
+
+		require.Equal(t, "capabilities:\n  metrics\n  http\nhttp:\n  /bye\n  /hello\n", out.String())
+	})
+}
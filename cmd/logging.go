@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/pflag"
+
+	"github.com/tscolari/servicetools/logging"
+)
+
+// logLevelPath is where the log level endpoint is mounted in the metrics server.
+const logLevelPath = "/admin/log-level"
+
+// loggingFlags adds the flags that configure the server logger.
+func loggingFlags(flags *pflag.FlagSet) {
+	flags.String("log-level", "info", "minimum level of the logs: debug, info, warn or error")
+	flags.String("log-format", logging.FormatJSON, "format of the logs: json or text")
+	flags.String("log-output", "stdout", "where to write the logs: stdout, stderr or a file path")
+}
+
+// newLogger creates the logger configured by the logging flags, with its level
+// backed by the given level var.
+// The returned closer must be called once the logger is no longer used.
+func newLogger(flags *pflag.FlagSet, level *slog.LevelVar) (*slog.Logger, func() error, error) {
+	levelName, _ := flags.GetString("log-level")
+
+	var initialLevel slog.Level
+	if err := initialLevel.UnmarshalText([]byte(levelName)); err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q: %w", levelName, err)
+	}
+
+	level.Set(initialLevel)
+
+	format, _ := flags.GetString("log-format")
+	if format != logging.FormatJSON && format != logging.FormatText {
+		return nil, nil, fmt.Errorf("invalid log format %q", format)
+	}
+
+	var output io.Writer
+	closer := func() error { return nil }
+
+	switch outputName, _ := flags.GetString("log-output"); outputName {
+	case "stdout", "":
+		output = os.Stdout
+	case "stderr":
+		output = os.Stderr
+	default:
+		file, err := os.OpenFile(outputName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log output: %w", err)
+		}
+
+		output = file
+		closer = file.Close
+	}
+
+	logger := logging.New(logging.Options{
+		Level:  level,
+		Format: format,
+		Output: output,
+	})
+
+	return logger, closer, nil
+}
+
+// watchLevelSignals changes the log level when SIGUSR1 (more verbose) or
+// SIGUSR2 (less verbose) are received, until the context is done.
+func watchLevelSignals(ctx context.Context, logger *slog.Logger, level *slog.LevelVar) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case s := <-signals:
+			steps := -1
+			if s == syscall.SIGUSR2 {
+				steps = 1
+			}
+
+			newLevel := logging.ShiftLevel(level, steps)
+			logger.Log(ctx, newLevel, "log level changed", "signal", s.String(), "level", newLevel.String())
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
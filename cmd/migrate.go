@@ -4,7 +4,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path"
+	"strconv"
+	"time"
 
 	_ "github.com/jackc/pgx/v5"
 	"github.com/spf13/cobra"
@@ -14,63 +19,353 @@ import (
 
 // CanMigrate injects the "migrate" subcommand to another command.
 func CanMigrate(rootCmd *cobra.Command) {
-	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(NewMigrateCommand("migrate"))
 }
 
-func init() {
-	// path should point to a folder migration files.
-	migrateCmd.PersistentFlags().StringVarP(&migratePath, "path", "p", "./migrations", "path to all migrations")
-	migrateCmd.PersistentFlags().StringVarP(&migrateEnvPrefix, "db-env-prefix", "e", "DATABASE", "prefix for all DB env variables")
+// CanMigrateFS is the same as CanMigrate, but the migrations are read from
+// the given filesystem (e.g. an embed.FS), with the `path` flag relative to it.
+// The "migrate create" subcommand still writes the new files to the `path` in disk.
+func CanMigrateFS(rootCmd *cobra.Command, migrations fs.FS) {
+	rootCmd.AddCommand(NewMigrateCommand("migrate", WithMigrationsFS(migrations)))
 }
 
-var (
-	migratePath      string
-	migrateEnvPrefix string
-)
+// NewMigrateCommand returns a command, with the given name, that migrates the database
+// the same way as the one injected by CanMigrate.
+// Each returned command has its own flags and state, so that one binary can
+// migrate multiple databases, e.g. using WithDatabaseEnvPrefix.
+func NewMigrateCommand(name string, opts ...MigrateCommandOption) *cobra.Command {
+	c := &migrateCommand{
+		path:           "./migrations",
+		envPrefix:      "DATABASE",
+		connectTimeout: 30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c.command(name)
+}
 
-// migrateCmd performs the database migration for the given path.
+// MigrateCommandOption customizes the command returned by NewMigrateCommand.
+type MigrateCommandOption func(*migrateCommand)
+
+// WithMigrationsFS makes the command read the migrations from the given filesystem
+// (e.g. an embed.FS), with the `path` flag relative to it.
+// The "create" subcommand still writes the new files to the `path` in disk.
+func WithMigrationsFS(migrations fs.FS) MigrateCommandOption {
+	return func(c *migrateCommand) {
+		c.fsys = migrations
+	}
+}
+
+// WithMigrationsPath sets the default value of the `path` flag.
+func WithMigrationsPath(migrationsPath string) MigrateCommandOption {
+	return func(c *migrateCommand) {
+		c.path = migrationsPath
+	}
+}
+
+// WithDatabaseEnvPrefix sets the default value of the `db-env-prefix` flag.
+func WithDatabaseEnvPrefix(prefix string) MigrateCommandOption {
+	return func(c *migrateCommand) {
+		c.envPrefix = prefix
+	}
+}
+
+// migrateCommand holds the state of a migrate command and its subcommands.
+type migrateCommand struct {
+	fsys           fs.FS
+	path           string
+	envPrefix      string
+	connectTimeout time.Duration
+	downAll        bool
+}
+
+// command returns the migrate command, which performs the database migration for the given path.
 // The connection to the database will use the given `db-env-prefix` for:
 // "_HOSTNAME", "_PORT", "_USERNAME", "_PASSWORD", "_NAME" and "_SSLMODE".
-var migrateCmd = &cobra.Command{
-	Use:   "migrate",
-	Short: "Migrates the database with the given migrations",
-	RunE: func(cmd *cobra.Command, args []string) error {
-
-		migrationStat, err := os.Stat(migratePath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to identify the migrations path: %v", err)
-			return err
-		}
-
-		if !migrationStat.IsDir() {
-			fmt.Fprintf(os.Stderr, "the given migrations path is not a directory")
-			return errors.New("the given migration path is not a directory")
-		}
-
-		var dbConfig *database.Config
-
-		dbConfig, err = database.ConfigFromEnv(migrateEnvPrefix)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to load configuration from env: %v\n", err)
-			return err
-		}
-
-		db, err := sql.Open("postgres", dbConfig.ToConnectStr())
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to connect to database: %v\n", err)
-			return err
-		}
-
-		if err := db.Ping(); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to check connection to database: %v\n", err)
-			return err
-		}
-
-		if err := database.Migrate(db, migratePath); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to migrate the database: %v\n", err)
-			return err
-		}
-
-		return nil
-	},
+func (c *migrateCommand) command(name string) *cobra.Command {
+	migrateCmd := &cobra.Command{
+		Use:   name,
+		Short: "Migrates the database with the given migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fsys, dir, err := c.migrationSource()
+			if err != nil {
+				return err
+			}
+
+			db, closeDB, err := c.openMigrationDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			if err := database.MigrateFS(db, fsys, dir); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to migrate the database: %v\n", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	// path should point to a folder migration files.
+	migrateCmd.PersistentFlags().StringVarP(&c.path, "path", "p", c.path, "path to all migrations")
+	migrateCmd.PersistentFlags().StringVarP(&c.envPrefix, "db-env-prefix", "e", c.envPrefix, "prefix for all DB env variables")
+	migrateCmd.PersistentFlags().DurationVar(&c.connectTimeout, "connect-timeout", c.connectTimeout, "how long to keep retrying to connect to the DB, 0 to try only once, unless set by the _CONNECT_TIMEOUT env variable")
+	loggingFlags(migrateCmd.PersistentFlags())
+
+	migrateCmd.AddCommand(
+		c.downCommand(),
+		c.gotoCommand(),
+		c.forceCommand(),
+		c.statusCommand(),
+		c.createCommand(),
+	)
+
+	return migrateCmd
+}
+
+// downCommand returns the command that rolls back the given number of migrations, 1 by default.
+func (c *migrateCommand) downCommand() *cobra.Command {
+	downCmd := &cobra.Command{
+		Use:   "down [n]",
+		Short: "Rolls back the last n migrations (1 by default)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			steps := 1
+			if len(args) > 0 {
+				var err error
+				steps, err = strconv.Atoi(args[0])
+				if err != nil || steps < 1 {
+					return fmt.Errorf("invalid number of migrations %q", args[0])
+				}
+			}
+
+			if c.downAll {
+				if len(args) > 0 {
+					return errors.New("the number of migrations can't be given with --all")
+				}
+
+				steps = 0
+			}
+
+			fsys, dir, err := c.migrationSource()
+			if err != nil {
+				return err
+			}
+
+			db, closeDB, err := c.openMigrationDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			if err := database.MigrateDownFS(db, fsys, dir, steps); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to roll back the database: %v\n", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	downCmd.Flags().BoolVar(&c.downAll, "all", false, "rolls back all migrations")
+
+	return downCmd
+}
+
+// gotoCommand returns the command that migrates the database up or down to the given version.
+func (c *migrateCommand) gotoCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "goto <version>",
+		Short: "Migrates the database up or down to the given version",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid version %q", args[0])
+			}
+
+			fsys, dir, err := c.migrationSource()
+			if err != nil {
+				return err
+			}
+
+			db, closeDB, err := c.openMigrationDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			if err := database.MigrateToFS(db, fsys, dir, uint(version)); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to migrate the database: %v\n", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+}
+
+// forceCommand returns the command that sets the database version, clearing the dirty flag,
+// without running any migrations.
+func (c *migrateCommand) forceCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "force <version>",
+		Short: "Sets the database version without running migrations, to recover from a dirty state",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.Atoi(args[0])
+			if err != nil || version < -1 {
+				return fmt.Errorf("invalid version %q", args[0])
+			}
+
+			fsys, dir, err := c.migrationSource()
+			if err != nil {
+				return err
+			}
+
+			db, closeDB, err := c.openMigrationDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			if err := database.ForceMigrationFS(db, fsys, dir, version); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to force the database version: %v\n", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+}
+
+// statusCommand returns the command that prints the current database version and the pending migrations.
+func (c *migrateCommand) statusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Shows the database version and the pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fsys, dir, err := c.migrationSource()
+			if err != nil {
+				return err
+			}
+
+			db, closeDB, err := c.openMigrationDB(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			status, err := database.ReadMigrationStatusFS(db, fsys, dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to read the migration status: %v\n", err)
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "version: %d\n", status.Version)
+			fmt.Fprintf(out, "dirty: %t\n", status.Dirty)
+			fmt.Fprintf(out, "pending: %d\n", len(status.Pending))
+
+			for _, migration := range status.Pending {
+				fmt.Fprintf(out, "  %s\n", migration)
+			}
+
+			return nil
+		},
+	}
+}
+
+// createCommand returns the command that creates empty, timestamped, up and down migration files.
+func (c *migrateCommand) createCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "create <name>",
+		Short: "Creates new up and down migration files",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := database.CreateMigration(c.path, args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create migration: %v\n", err)
+				return err
+			}
+
+			for _, file := range files {
+				fmt.Fprintln(cmd.OutOrStdout(), file)
+			}
+
+			return nil
+		},
+	}
+}
+
+// migrationSource returns the filesystem and the directory in it with the migrations.
+func (c *migrateCommand) migrationSource() (fs.FS, string, error) {
+	fsys, dir := c.fsys, path.Clean(c.path)
+	if fsys == nil {
+		fsys, dir = os.DirFS(c.path), "."
+	}
+
+	migrationStat, err := fs.Stat(fsys, dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to identify the migrations path: %v", err)
+		return nil, "", err
+	}
+
+	if !migrationStat.IsDir() {
+		fmt.Fprintf(os.Stderr, "the given migrations path is not a directory")
+		return nil, "", errors.New("the given migration path is not a directory")
+	}
+
+	return fsys, dir, nil
+}
+
+// openMigrationDB opens the connection to the database, logging with the logger
+// configured by the logging flags.
+// Connection attempts are retried according to the `_CONNECT_TIMEOUT`,
+// `_CONNECT_RETRY_INTERVAL` and `_CONNECT_RETRY_MAX_INTERVAL` env variables.
+// The returned closer closes both the connection and the logger.
+func (c *migrateCommand) openMigrationDB(cmd *cobra.Command) (*sql.DB, func() error, error) {
+	dbConfig, err := c.migrationDBConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration from env: %v\n", err)
+		return nil, nil, err
+	}
+
+	logger, closeLogger, err := newLogger(cmd.Flags(), new(slog.LevelVar))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := database.Open(cmd.Context(), logger, dbConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to database: %v\n", err)
+		closeLogger()
+		return nil, nil, err
+	}
+
+	return db, func() error {
+		return errors.Join(db.Close(), closeLogger())
+	}, nil
+}
+
+// migrationDBConfig returns the database configuration from the env variables.
+// Like in the server command, the connection is retried for 30 seconds by default
+// (see the `connect-timeout` flag), so that the database has time to come up.
+func (c *migrateCommand) migrationDBConfig() (*database.Config, error) {
+	dbConfig, err := database.ConfigFromEnv(c.envPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := os.LookupEnv(c.envPrefix + "_CONNECT_TIMEOUT"); !ok {
+		dbConfig.ConnectTimeout = c.connectTimeout
+	}
+
+	return dbConfig, nil
 }
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewMigrateCommand(t *testing.T) {
+	t.Run("independent commands", func(t *testing.T) {
+		usersPath := t.TempDir()
+		ordersPath := t.TempDir()
+
+		rootCmd := &cobra.Command{Use: "test"}
+		rootCmd.AddCommand(
+			NewMigrateCommand("migrate-users", WithMigrationsPath(usersPath), WithDatabaseEnvPrefix("USERS_DATABASE")),
+			NewMigrateCommand("migrate-orders", WithMigrationsPath(ordersPath)),
+		)
+
+		executeCommand(t, rootCmd, "migrate-users", "create", "add_users")
+		executeCommand(t, rootCmd, "migrate-orders", "create", "add_orders")
+
+		files, err := filepath.Glob(filepath.Join(usersPath, "*_add_users.*.sql"))
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+
+		files, err = filepath.Glob(filepath.Join(ordersPath, "*_add_orders.*.sql"))
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+
+		usersCmd, _, err := rootCmd.Find([]string{"migrate-users"})
+		require.NoError(t, err)
+		require.Equal(t, "USERS_DATABASE", usersCmd.PersistentFlags().Lookup("db-env-prefix").Value.String())
+
+		ordersCmd, _, err := rootCmd.Find([]string{"migrate-orders"})
+		require.NoError(t, err)
+		require.Equal(t, "DATABASE", ordersCmd.PersistentFlags().Lookup("db-env-prefix").Value.String())
+	})
+
+	t.Run("connect timeout", func(t *testing.T) {
+		t.Setenv("TEST_DATABASE_HOSTNAME", "localhost")
+		t.Setenv("TEST_DATABASE_PORT", "5432")
+
+		c := &migrateCommand{envPrefix: "TEST_DATABASE", connectTimeout: 30 * time.Second}
+
+		config, err := c.migrationDBConfig()
+		require.NoError(t, err)
+		require.Equal(t, 30*time.Second, config.ConnectTimeout)
+
+		t.Setenv("TEST_DATABASE_CONNECT_TIMEOUT", "0s")
+
+		config, err = c.migrationDBConfig()
+		require.NoError(t, err)
+		require.Zero(t, config.ConnectTimeout)
+
+		cmd := NewMigrateCommand("migrate")
+		require.Equal(t, "30s", cmd.PersistentFlags().Lookup("connect-timeout").Value.String())
+	})
+
+	t.Run("migrations path not a directory", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "file.sql")
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+		cmd := NewMigrateCommand("migrate", WithMigrationsPath(path))
+		cmd.SetArgs([]string{"status"})
+		require.ErrorContains(t, cmd.Execute(), "not a directory")
+	})
+
+	t.Run("logging flags", func(t *testing.T) {
+		t.Setenv("TEST_DATABASE_HOSTNAME", "localhost")
+		t.Setenv("TEST_DATABASE_PORT", "5432")
+
+		// The logger is configured before connecting to the database.
+		cmd := NewMigrateCommand("migrate", WithMigrationsPath(t.TempDir()), WithDatabaseEnvPrefix("TEST_DATABASE"))
+		cmd.SetArgs([]string{"status", "--log-level", "verbose"})
+		require.ErrorContains(t, cmd.Execute(), `invalid log level "verbose"`)
+	})
+}
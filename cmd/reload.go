@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/pflag"
+
+	"github.com/tscolari/servicetools/server"
+)
+
+// reloader re-reads the configuration and applies it to the running
+// components and to the Server, without restarting them.
+type reloader struct {
+	flags *pflag.FlagSet
+	level *slog.LevelVar
+
+	components []reloadableComponent
+
+	// onReload is called after the components are reloaded.
+	onReload func(context.Context, *slog.Logger) error
+}
+
+type reloadableComponent struct {
+	capability Capability
+	component  server.Component
+}
+
+// add registers a component to be reloaded, if its capability supports it.
+func (r *reloader) add(capability Capability, component server.Component) {
+	if capability.Reload != nil {
+		r.components = append(r.components, reloadableComponent{capability: capability, component: component})
+	}
+}
+
+// reload re-reads the configuration, reloads the components in order and calls the onReload hook.
+// A component failing to reload doesn't prevent the others from reloading.
+func (r *reloader) reload(ctx context.Context, logger *slog.Logger) error {
+	if err := loadConfig(r.flags); err != nil {
+		return fmt.Errorf("failed to reload configuration: %w", err)
+	}
+
+	var errs []error
+
+	levelName, _ := r.flags.GetString("log-level")
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level %q: %w", levelName, err))
+	} else {
+		r.level.Set(level)
+	}
+
+	for _, c := range r.components {
+		if err := c.capability.Reload(ctx, logger, r.flags, c.component); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reload %s: %w", c.capability.Name, err))
+		}
+	}
+
+	if r.onReload != nil {
+		if err := r.onReload(ctx, logger); err != nil {
+			errs = append(errs, fmt.Errorf("server reload failed: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// watch reloads on every SIGHUP, until the context is done.
+func (r *reloader) watch(ctx context.Context, logger *slog.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-signals:
+			logger.Info("reloading")
+
+			if err := r.reload(ctx, logger); err != nil {
+				logger.Error("reload failed", "error", err)
+				continue
+			}
+
+			logger.Info("reloaded")
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tscolari/servicetools/server"
+)
+
+func Test_Reloader(t *testing.T) {
+	newFlags := func(t *testing.T, path string) *pflag.FlagSet {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		configFlags(flags)
+		loggingFlags(flags)
+		flags.String("custom-value", "default", "")
+
+		require.NoError(t, flags.Parse([]string{"--config", path}))
+		require.NoError(t, loadConfig(flags))
+		return flags
+	}
+
+	t.Run("reloads the configuration, the components and the server", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "custom-value: first\n")
+		flags := newFlags(t, path)
+
+		var reloadedValues []string
+		capability := Capability{
+			Name: "custom",
+			Reload: func(ctx context.Context, logger *slog.Logger, flags *pflag.FlagSet, component server.Component) error {
+				value, _ := flags.GetString("custom-value")
+				reloadedValues = append(reloadedValues, value)
+				return nil
+			},
+		}
+
+		reloadHook := NewMockHasReload(t)
+		reloadHook.On("OnReload", mock.Anything, mock.Anything).Return(nil).Once()
+
+		level := new(slog.LevelVar)
+		r := &reloader{flags: flags, level: level, onReload: reloadHook.OnReload}
+		r.add(capability, server.NewMockComponent(t))
+		r.add(Capability{Name: "not-reloadable"}, server.NewMockComponent(t))
+
+		require.NoError(t, os.WriteFile(path, []byte("custom-value: second\nlog-level: debug\n"), 0o600))
+		require.NoError(t, r.reload(context.Background(), slog.Default()))
+
+		require.Equal(t, []string{"second"}, reloadedValues)
+		require.Equal(t, slog.LevelDebug, level.Level())
+	})
+
+	t.Run("failing components don't stop the others", func(t *testing.T) {
+		flags := newFlags(t, writeConfigFile(t, "config.yaml", "{}"))
+
+		reloadErr := errors.New("failed")
+		called := false
+
+		r := &reloader{flags: flags, level: new(slog.LevelVar)}
+		r.add(Capability{
+			Name: "failing",
+			Reload: func(context.Context, *slog.Logger, *pflag.FlagSet, server.Component) error {
+				return reloadErr
+			},
+		}, server.NewMockComponent(t))
+		r.add(Capability{
+			Name: "working",
+			Reload: func(context.Context, *slog.Logger, *pflag.FlagSet, server.Component) error {
+				called = true
+				return nil
+			},
+		}, server.NewMockComponent(t))
+
+		err := r.reload(context.Background(), slog.Default())
+		require.ErrorIs(t, err, reloadErr)
+		require.ErrorContains(t, err, "failed to reload failing")
+		require.True(t, called)
+	})
+
+	t.Run("slice flags are replaced", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "custom-items: [a, b]\n")
+		t.Setenv("CUSTOM_BUCKETS", "0.5,1")
+
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		configFlags(flags)
+		loggingFlags(flags)
+		flags.StringSlice("custom-items", nil, "")
+		flags.Float64Slice("custom-buckets", []float64{0.1}, "")
+		bindEnv(flags, "custom-buckets", "CUSTOM_BUCKETS")
+
+		require.NoError(t, flags.Parse([]string{"--config", path}))
+		require.NoError(t, loadConfig(flags))
+
+		r := &reloader{flags: flags, level: new(slog.LevelVar)}
+		require.NoError(t, r.reload(context.Background(), slog.Default()))
+		require.NoError(t, r.reload(context.Background(), slog.Default()))
+
+		items, _ := flags.GetStringSlice("custom-items")
+		require.Equal(t, []string{"a", "b"}, items)
+
+		buckets, _ := flags.GetFloat64Slice("custom-buckets")
+		require.Equal(t, []float64{0.5, 1}, buckets)
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "{}")
+		flags := newFlags(t, path)
+
+		r := &reloader{flags: flags, level: new(slog.LevelVar)}
+
+		require.NoError(t, os.WriteFile(path, []byte("unknown-key: true\n"), 0o600))
+		require.ErrorContains(t, r.reload(context.Background(), slog.Default()), "failed to reload configuration")
+	})
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tscolari/servicetools/logging"
+	"github.com/tscolari/servicetools/server"
+)
+
+// newRunTaskCommand returns the command that runs a single worker task of the given server,
+// e.g. from a cron job, until it returns or a stop signal is received.
+// Every component is configured as it would be by the server command, so that the
+// task has the same dependencies (e.g. the databases), but none of them is started.
+// The command fails if the task fails, so that the exit status reflects the result.
+// The flags of the server command must be added to, or inherited by, it.
+func newRunTaskCommand(srv Server, capabilities func() []Capability) *cobra.Command {
+	var list bool
+
+	runTaskCmd := &cobra.Command{
+		Use:   "run-task <name>",
+		Short: "Runs a single worker task, and exits once it's finished",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if list {
+				return cobra.NoArgs(cmd, args)
+			}
+
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(cmd.Flags()); err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+
+			if list {
+				return listTasks(cmd, srv, capabilities())
+			}
+
+			level := new(slog.LevelVar)
+			logger, closeLogger, err := newLogger(cmd.Flags(), level)
+			if err != nil {
+				return err
+			}
+			defer closeLogger()
+
+			// Loggers taken from contexts without one should behave the same.
+			logging.Default = func() *slog.Logger { return logger }
+
+			closeTracing, err := setupTracing(cmd.Flags(), logger)
+			if err != nil {
+				return err
+			}
+			defer closeTracing()
+
+			ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+			defer cancel()
+
+			// The supervisor is only used to close the components once the task is done.
+			supervisor := &supervisor{}
+			defer supervisor.close(logger)
+
+			var worker *server.WithWorker
+			for _, capability := range capabilities() {
+				component, err := capability.New(ctx, logger, cmd.Flags(), srv)
+				if err != nil {
+					logger.Error("failed to configure component", "component", capability.Name, "error", err)
+					return fmt.Errorf("failed to configure %s: %w", capability.Name, err)
+				}
+
+				supervisor.add(component)
+
+				if withWorker, ok := component.(*server.WithWorker); ok {
+					worker = withWorker
+				}
+			}
+
+			if worker == nil {
+				return errors.New("the server has no worker capability")
+			}
+
+			if err := worker.RunTask(ctx, logger, args[0]); err != nil {
+				logger.Error("task failed", "task", args[0], "error", err)
+				return err
+			}
+
+			logger.Info("task finished", "task", args[0])
+			return nil
+		},
+	}
+
+	runTaskCmd.Flags().BoolVar(&list, "list", false, "lists the available tasks, instead of running one")
+
+	return runTaskCmd
+}
+
+// listTasks prints the names of the worker tasks of the given server, one per line.
+// Only the worker is configured, so no connections are made.
+func listTasks(cmd *cobra.Command, srv Server, capabilities []Capability) error {
+	// The worker is never started, so there's nothing worth logging.
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, capability := range capabilities {
+		if capability.Name != "worker" {
+			continue
+		}
+
+		component, err := capability.New(cmd.Context(), logger, cmd.Flags(), srv)
+		if err != nil {
+			return fmt.Errorf("failed to configure %s: %w", capability.Name, err)
+		}
+
+		for _, name := range component.(*server.WithWorker).TaskNames() {
+			fmt.Fprintln(cmd.OutOrStdout(), name)
+		}
+
+		return nil
+	}
+
+	return errors.New("the server has no worker capability")
+}
@@ -2,23 +2,54 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
 
-	"github.com/tscolari/servicetools/database"
+	"github.com/tscolari/servicetools/buildinfo"
 	"github.com/tscolari/servicetools/logging"
 	"github.com/tscolari/servicetools/server"
+	grpcsrv "github.com/tscolari/servicetools/server/grpc"
 )
 
-// Server defines the interface that this command uses to start/stop.
-type Server interface {
-	Start(context.Context, *slog.Logger) error
-	Stop(context.Context, *slog.Logger) error
+// defaultEnvPrefix is the prefix of the env variables that back the server flags.
+const defaultEnvPrefix = "SERVICE"
+
+// Server defines the service that this command runs.
+// The command configures, starts and stops every component that the
+// Server has capability for (see the Has* interfaces and WithCustomCapabilities).
+// Optionally, the Server can hook into that lifecycle by implementing
+// HasStartHook, HasStopHook and/or HasReload.
+type Server interface{}
+
+// HasStartHook means the Server wants to be called once all of its components
+// have started. The context given to OnStart is canceled when the server is stopping.
+// Returning an error from OnStart will cause the server to stop.
+type HasStartHook interface {
+	OnStart(context.Context, *slog.Logger) error
+}
+
+// HasStopHook means the Server wants to be called when the server is stopping,
+// before any of its components are stopped.
+type HasStopHook interface {
+	OnStop(context.Context, *slog.Logger) error
+}
+
+// HasReload means the Server wants to be called when the server is reloaded (on SIGHUP),
+// after the configuration was re-read and the components were reloaded.
+// In-flight requests are not affected by reloads.
+type HasReload interface {
+	OnReload(context.Context, *slog.Logger) error
 }
 
 // HasGRPC means the Server has gRPC capability.
@@ -46,132 +77,355 @@ type HasDatabase interface {
 	ConfigureDatabase(*server.WithDB)
 }
 
+// HasMigrations means the Server embeds its migrations (e.g. using embed.FS).
+// It's used by the `--migrate-on-start` flag, with `--migrations-path` being
+// relative to the returned filesystem.
+type HasMigrations interface {
+	Migrations() fs.FS
+}
+
 // HasReaderDatabase means the Server has database reader capability.
 type HasReaderDatabase interface {
 	ConfigureReaderDatabase(*server.WithRDB)
 }
 
-// CanServer injects the "server" (or start) subcommand to another command.
-// It will start the given Server based on the capabilities that it implements.
-func CanServer(rootCmd *cobra.Command, srv Server) {
-	serverToRun = srv
+// CanServer injects the "server" (or start) subcommand to another command,
+// along with the "config" and "describe" subcommands that inspect it, the "run-task"
+// subcommand if the Server has worker capability, and the "version" subcommand.
+// It will configure the given Server based on the capabilities that it implements,
+// start all of its components concurrently and block until a stop signal is received
+// or any of the components fails.
+// Components are stopped in reverse dependency order: first the ones that receive
+// traffic (gRPC, HTTP), then the worker, the metrics server and lastly the databases.
+// To expose more than one Server in the same binary, use NewServerCommand instead.
+func CanServer(rootCmd *cobra.Command, srv Server, opts ...ServerCommandOption) {
+	c := newServerCommand("server", srv, opts...)
+	c.cmd.Aliases = []string{"start"}
+
+	// The subcommands use the same flags as the server command.
+	for _, subCmd := range c.subCommands() {
+		subCmd.PersistentFlags().AddFlagSet(c.cmd.PersistentFlags())
+		rootCmd.AddCommand(subCmd)
+	}
 
-	// Enable only the flags that the given server supports:
+	rootCmd.AddCommand(c.cmd, NewVersionCommand())
+}
 
-	if _, ok := serverToRun.(HasDatabase); ok {
-		serverCmd.PersistentFlags().StringVar(&serverDBEnvPrefix, "db-env-prefix", "DATABASE", "prefix to env variables with DB configuration")
-	}
+// NewServerCommand returns a command, with the given name, that runs the given Server
+// the same way as the one injected by CanServer.
+// The "config", "describe" and, with worker capability, "run-task" subcommands
+// are added to it, e.g. `api config print`.
+// Each returned command has its own flags and state, so that one binary can expose
+// multiple servers, e.g. `api` and `worker`.
+func NewServerCommand(name string, srv Server, opts ...ServerCommandOption) *cobra.Command {
+	c := newServerCommand(name, srv, opts...)
+	c.cmd.AddCommand(c.subCommands()...)
+
+	return c.cmd
+}
 
-	if _, ok := serverToRun.(HasReaderDatabase); ok {
-		serverCmd.PersistentFlags().StringVar(&serverRDBEnvPrefix, "reader-db-env-prefix", "DATABASE_READER", "prefix to env variables with READER DB configuration")
-	}
+// ServerCommandOption customizes the command returned by NewServerCommand, or injected by CanServer.
+type ServerCommandOption func(*serverCommand)
 
-	if _, ok := serverToRun.(HasGRPC); ok {
-		serverCmd.PersistentFlags().StringVar(&serverGRPCAddress, "grpc-address", "localhost:0", "listening address for GRPC connections")
+// WithCapabilities restricts the command to the capabilities with the given names,
+// e.g. "grpc" or "worker", even if the Server supports others.
+// This allows the same Server to be split in different commands.
+func WithCapabilities(names ...string) ServerCommandOption {
+	return func(c *serverCommand) {
+		c.capabilityNames = names
 	}
+}
 
-	if _, ok := serverToRun.(HasHTTP); ok {
-		serverCmd.PersistentFlags().StringVar(&serverHTTPAddress, "http-address", "localhost:0", "listening address for HTTP connections")
+// WithCustomCapabilities adds custom capabilities to the command, so that it adds their
+// flags and manages their components like the built-in ones.
+// Custom components are started after, and stopped before, the built-in ones.
+func WithCustomCapabilities(capabilities ...Capability) ServerCommandOption {
+	return func(c *serverCommand) {
+		c.customCapabilities = append(c.customCapabilities, capabilities...)
 	}
+}
 
-	if _, ok := serverToRun.(HasMetrics); ok {
-		serverCmd.PersistentFlags().StringVar(&serverMetricsAddress, "metrics-address", "localhost:0", "listening address for metrics")
+// WithEnvPrefix sets the prefix of the env variables that back the flags of the command,
+// e.g. "API" for `API_GRPC_ADDRESS`. It defaults to "SERVICE".
+// Flags with their own env variables, like the database ones, are not affected.
+func WithEnvPrefix(prefix string) ServerCommandOption {
+	return func(c *serverCommand) {
+		c.envPrefix = prefix
 	}
-
-	rootCmd.AddCommand(serverCmd)
 }
 
-var (
-	serverToRun Server
+// serverCommand holds the state of a server command and its inspection subcommands.
+type serverCommand struct {
+	srv                Server
+	customCapabilities []Capability
+	capabilityNames    []string
+	envPrefix          string
+
+	cmd         *cobra.Command
+	configCmd   *cobra.Command
+	describeCmd *cobra.Command
+	runTaskCmd  *cobra.Command
+}
 
-	serverGRPCAddress    string
-	serverHTTPAddress    string
-	serverMetricsAddress string
-	serverDBEnvPrefix    string
-	serverRDBEnvPrefix   string
-)
+func newServerCommand(name string, srv Server, opts ...ServerCommandOption) *serverCommand {
+	c := &serverCommand{srv: srv, envPrefix: defaultEnvPrefix}
+	for _, opt := range opts {
+		opt(c)
+	}
 
-var serverCmd = &cobra.Command{
-	Use:     "server",
-	Aliases: []string{"start"},
-	Short:   "Starts the server",
-	RunE: func(cmd *cobra.Command, args []string) error {
+	c.cmd = &cobra.Command{
+		Use:   name,
+		Short: "Starts the server",
+		Args:  cobra.NoArgs,
+		RunE:  c.run,
+	}
 
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		logger := logging.Default()
+	flags := c.cmd.PersistentFlags()
+	configFlags(flags)
+	loggingFlags(flags)
+	tracingFlags(flags)
+	flags.Duration("shutdown-timeout", 30*time.Second, "maximum time to stop the server gracefully, before forcing it, 0 for no limit")
+	flags.Duration("drain-delay", 0, "time to keep serving, while reporting not-ready, before stopping the server")
 
-		if grpcSrv, ok := serverToRun.(HasGRPC); ok {
-			withGRPC := server.NewWithGRPC(serverGRPCAddress)
-			grpcSrv.ConfigureGRPC(withGRPC)
+	// Enable only the flags that the given server supports:
+	for _, capability := range c.capabilities() {
+		if capability.Flags != nil {
+			capability.Flags(flags)
 		}
+	}
 
-		if httpSrv, ok := serverToRun.(HasHTTP); ok {
-			withHTTP := server.NewWithHTTP(serverHTTPAddress)
-			httpSrv.ConfigureHTTP(withHTTP)
+	bindEnvPrefix(flags, c.envPrefix)
+
+	c.configCmd = newConfigCommand()
+	c.describeCmd = newDescribeCommand(c.srv, c.capabilities)
+
+	for _, capability := range c.capabilities() {
+		if capability.Name == "worker" {
+			c.runTaskCmd = newRunTaskCommand(c.srv, c.capabilities)
 		}
+	}
 
-		if workerSrv, ok := serverToRun.(HasWorker); ok {
-			withWorker := server.NewWithWorker()
-			workerSrv.ConfigureWorker(withWorker)
+	return c
+}
+
+// subCommands returns the commands that share the flags of the server command.
+func (c *serverCommand) subCommands() []*cobra.Command {
+	subCommands := []*cobra.Command{c.configCmd, c.describeCmd}
+	if c.runTaskCmd != nil {
+		subCommands = append(subCommands, c.runTaskCmd)
+	}
+
+	return subCommands
+}
+
+// capabilities returns the capabilities that the server supports and the command enables.
+func (c *serverCommand) capabilities() []Capability {
+	capabilities := capabilitiesFor(c.srv, c.customCapabilities)
+	if c.capabilityNames == nil {
+		return capabilities
+	}
+
+	enabled := []Capability{}
+	for _, capability := range capabilities {
+		if slices.Contains(c.capabilityNames, capability.Name) {
+			enabled = append(enabled, capability)
 		}
+	}
+
+	return enabled
+}
+
+func (c *serverCommand) run(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(cmd.Flags()); err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	level := new(slog.LevelVar)
+	logger, closeLogger, err := newLogger(cmd.Flags(), level)
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	// Loggers taken from contexts without one should behave the same.
+	logging.Default = func() *slog.Logger { return logger }
+
+	closeTracing, err := setupTracing(cmd.Flags(), logger)
+	if err != nil {
+		return err
+	}
+	defer closeTracing()
 
-		if metricsSrv, ok := serverToRun.(HasMetrics); ok {
-			withMetrics := server.NewWithMetrics(serverMetricsAddress)
-			metricsSrv.ConfigureMetrics(withMetrics)
+	logger.Info("starting server", "build", buildinfo.Read())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go watchLevelSignals(ctx, logger, level)
+
+	supervisor := &supervisor{}
+	supervisor.drainDelay, _ = cmd.Flags().GetDuration("drain-delay")
+	supervisor.shutdownTimeout, _ = cmd.Flags().GetDuration("shutdown-timeout")
+
+	reloader := &reloader{flags: cmd.Flags(), level: level}
+
+	for _, capability := range c.capabilities() {
+		component, err := capability.New(ctx, logger, cmd.Flags(), c.srv)
+		if err != nil {
+			logger.Error("failed to configure component", "component", capability.Name, "error", err)
+			supervisor.close(logger)
+			return fmt.Errorf("failed to configure %s: %w", capability.Name, err)
 		}
 
-		if withDBSrv, ok := serverToRun.(HasDatabase); ok {
-			dbConfig, err := database.ConfigFromEnv(serverDBEnvPrefix)
-			if err != nil {
-				logger.Error("failed to load database configuration", "error", err)
-				return fmt.Errorf("failed to generate DB configuration: %w", err)
+		if capability.Prepare != nil {
+			if err := capability.Prepare(cmd.Flags(), component); err != nil {
+				logger.Error("failed to prepare component", "component", capability.Name, "error", err)
+				supervisor.close(logger)
+				return fmt.Errorf("failed to prepare %s: %w", capability.Name, err)
 			}
+		}
 
-			withDB, err := server.NewWithDB(dbConfig)
-			if err != nil {
-				logger.Error("failed to configure database", "error", err)
-				return fmt.Errorf("failed to configure DB: %w", err)
+		supervisor.add(component)
+		reloader.add(capability, component)
+	}
+
+	if err := shareGRPCListener(cmd.Flags(), supervisor.components); err != nil {
+		supervisor.close(logger)
+		return err
+	}
+
+	if err := instrumentGRPC(cmd.Flags(), supervisor.components); err != nil {
+		supervisor.close(logger)
+		return err
+	}
+
+	for _, component := range supervisor.components {
+		if withMetrics, ok := component.(*server.WithMetrics); ok {
+			withMetrics.Handle(logLevelPath, logging.LevelHandler(level))
+
+			healthHandler := withMetrics.HealthHandler()
+			healthHandler.AddReadinessCheck("components", supervisor.readinessCheck)
+
+			for i, c := range supervisor.components {
+				healthHandler.AddReadinessCheck(c.Name(), supervisor.runningCheck(i))
 			}
-			withDBSrv.ConfigureDatabase(withDB)
 		}
+	}
 
-		if withRDBSrv, ok := serverToRun.(HasReaderDatabase); ok {
-			dbConfig, err := database.ConfigFromEnv(serverRDBEnvPrefix)
-			if err != nil {
-				logger.Error("failed to load database configuration", "error", err)
-				return fmt.Errorf("failed to generate DB configuration: %w", err)
-			}
+	if startHook, ok := c.srv.(HasStartHook); ok {
+		supervisor.onStarted = startHook.OnStart
+	}
 
-			withRDB, err := server.NewWithRDB(dbConfig)
-			if err != nil {
-				logger.Error("failed to configure reader database", "error", err)
-				return fmt.Errorf("failed to configure Reader DB: %w", err)
-			}
-			withRDBSrv.ConfigureReaderDatabase(withRDB)
+	if stopHook, ok := c.srv.(HasStopHook); ok {
+		supervisor.onStop = stopHook.OnStop
+	}
+
+	if reloadHook, ok := c.srv.(HasReload); ok {
+		reloader.onReload = reloadHook.OnReload
+	}
+
+	go reloader.watch(ctx, logger)
+
+	exited := make(chan struct{})
+	defer close(exited)
+
+	go func() {
+		stopSignal := make(chan os.Signal, 1)
+		signal.Notify(stopSignal, syscall.SIGTERM, syscall.SIGINT)
+		defer signal.Stop(stopSignal)
+
+		select {
+		case s := <-stopSignal:
+			logger.Info("signal received, exiting", "signal", s.String())
+			cancel()
+		case <-exited:
+			return
 		}
 
-		go func() {
-			stopSignal := make(chan os.Signal, 1)
-			signal.Notify(stopSignal, syscall.SIGTERM, syscall.SIGINT)
+		// A second signal means the graceful shutdown shouldn't be waited for.
+		select {
+		case s := <-stopSignal:
+			logger.Warn("second signal received, forcing exit", "signal", s.String())
+			os.Exit(1)
+		case <-exited:
+		}
+	}()
 
-			select {
-			case s := <-stopSignal:
-				logger.Info("signal received, exiting", "signal", s.String())
-			case <-ctx.Done():
-				logger.Info("server context was closed, exiting")
-			}
+	if err := supervisor.run(ctx, logger); err != nil {
+		logger.Error("server failed", "error", err)
+		return err
+	}
 
-			if err := serverToRun.Stop(ctx, logger); err != nil {
-				logger.Error("attempt to stop server failed", "error", err)
-			}
-		}()
+	return nil
+}
 
-		if err := serverToRun.Start(ctx, logger); err != nil {
-			logger.Error("server failed", "error", err)
-			return err
+// shareGRPCListener makes the gRPC server use the listener of the HTTP server,
+// if requested by the `grpc-on-http` flag, which can't be combined with the gRPC TLS flags.
+func shareGRPCListener(flags *pflag.FlagSet, components []server.Component) error {
+	if onHTTP, _ := flags.GetBool("grpc-on-http"); !onHTTP {
+		return nil
+	}
+
+	withGRPC := findComponent[*server.WithGRPC](components)
+	withHTTP := findComponent[*server.WithHTTP](components)
+
+	if withGRPC == nil || withHTTP == nil {
+		return errors.New("--grpc-on-http requires both the grpc and http capabilities")
+	}
+
+	// TLS is terminated by the HTTP server, so the gRPC certificates would be ignored.
+	for _, name := range []string{"grpc-tls-cert", "grpc-tls-key", "grpc-tls-client-ca"} {
+		if value, _ := flags.GetString(name); value != "" {
+			return fmt.Errorf("--grpc-on-http can't be used with --%s, use the --http-tls-* flags instead", name)
 		}
+	}
+
+	withGRPC.ServeWithHTTP(withHTTP)
+	return nil
+}
 
+// instrumentGRPC records the metrics of the gRPC server (see grpcsrv.Metrics), and
+// its recovered panics, in the metrics server, if the Server has both capabilities.
+func instrumentGRPC(flags *pflag.FlagSet, components []server.Component) error {
+	withGRPC := findComponent[*server.WithGRPC](components)
+	withMetrics := findComponent[*server.WithMetrics](components)
+
+	if withGRPC == nil || withMetrics == nil {
 		return nil
-	},
+	}
+
+	buckets, _ := flags.GetFloat64Slice("grpc-metrics-buckets")
+	excluded, _ := flags.GetStringSlice("grpc-metrics-exclude")
+
+	metrics := grpcsrv.NewMetrics(grpcsrv.MetricsOptions{
+		Buckets:         buckets,
+		ExcludedMethods: excluded,
+	})
+
+	if err := withMetrics.Registerer().Register(metrics); err != nil {
+		return fmt.Errorf("failed to register GRPC metrics: %w", err)
+	}
+
+	if err := withMetrics.Registerer().Register(withGRPC.Recovery()); err != nil {
+		return fmt.Errorf("failed to register GRPC recovery metrics: %w", err)
+	}
+
+	withGRPC.AddOptions(
+		grpc.ChainUnaryInterceptor(metrics.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(metrics.StreamInterceptor),
+	)
+
+	return nil
+}
+
+// findComponent returns the component of the given type, or its zero value if there's none.
+func findComponent[T server.Component](components []server.Component) T {
+	for _, component := range components {
+		if c, ok := component.(T); ok {
+			return c
+		}
+	}
+
+	var zero T
+	return zero
 }
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/tscolari/servicetools/server"
+)
+
+type testAPIServer struct {
+	*server.WithHTTP
+	*server.WithWorker
+}
+
+func (s *testAPIServer) ConfigureHTTP(w *server.WithHTTP) {
+	s.WithHTTP = w
+	w.Register(func(handle func(string, func(http.ResponseWriter, *http.Request))) {
+		handle("/api", func(http.ResponseWriter, *http.Request) {})
+	})
+}
+
+func (s *testAPIServer) ConfigureWorker(w *server.WithWorker) {
+	s.WithWorker = w
+	w.Register(func(ctx context.Context, _ *slog.Logger) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	w.RegisterNamed("succeed", func(context.Context, *slog.Logger) error {
+		return nil
+	})
+
+	w.RegisterNamed("fail", func(context.Context, *slog.Logger) error {
+		return errors.New("task failed")
+	})
+}
+
+type testAdminServer struct {
+	*server.WithHTTP
+}
+
+func (s *testAdminServer) ConfigureHTTP(w *server.WithHTTP) {
+	s.WithHTTP = w
+	w.Register(func(handle func(string, func(http.ResponseWriter, *http.Request))) {
+		handle("/admin", func(http.ResponseWriter, *http.Request) {})
+	})
+}
+
+func executeCommand(t *testing.T, cmd *cobra.Command, args ...string) string {
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs(args)
+
+	require.NoError(t, cmd.Execute())
+	return out.String()
+}
+
+func Test_NewServerCommand(t *testing.T) {
+	t.Run("independent commands", func(t *testing.T) {
+		rootCmd := &cobra.Command{Use: "test"}
+		rootCmd.AddCommand(
+			NewServerCommand("api", &testAPIServer{}),
+			NewServerCommand("admin", &testAdminServer{}),
+		)
+
+		out := executeCommand(t, rootCmd, "api", "describe", "--http-address", "localhost:1000")
+		require.Contains(t, out, "/api")
+		require.Contains(t, out, "worker")
+		require.NotContains(t, out, "/admin")
+
+		out = executeCommand(t, rootCmd, "admin", "describe")
+		require.Contains(t, out, "/admin")
+		require.NotContains(t, out, "worker")
+
+		// Flags given to one command don't leak into the other.
+		out = executeCommand(t, rootCmd, "admin", "config", "print")
+		require.NotContains(t, out, "localhost:1000")
+		require.NotContains(t, out, "worker")
+	})
+
+	t.Run("restricting capabilities", func(t *testing.T) {
+		cmd := NewServerCommand("worker", &testAPIServer{}, WithCapabilities("worker"))
+
+		out := executeCommand(t, cmd, "describe")
+		require.Equal(t, "capabilities:\n  worker\nworker:\n  fail\n  github.com/tscolari/servicetools/cmd.(*testAPIServer).ConfigureWorker.func1\n  succeed\n", out)
+
+		require.Nil(t, cmd.PersistentFlags().Lookup("http-address"))
+	})
+
+	t.Run("custom capabilities", func(t *testing.T) {
+		custom := Capability{
+			Name:     "custom",
+			Supports: func(Server) bool { return true },
+			Flags: func(flags *pflag.FlagSet) {
+				flags.String("custom-address", "", "")
+			},
+		}
+
+		withCustom := NewServerCommand("api", &testAdminServer{}, WithCustomCapabilities(custom))
+		require.NotNil(t, withCustom.PersistentFlags().Lookup("custom-address"))
+
+		out := executeCommand(t, withCustom, "describe")
+		require.Contains(t, out, "custom")
+
+		// Custom capabilities of one command don't leak into others.
+		withoutCustom := NewServerCommand("admin", &testAdminServer{})
+		require.Nil(t, withoutCustom.PersistentFlags().Lookup("custom-address"))
+	})
+
+	t.Run("env prefix", func(t *testing.T) {
+		t.Setenv("ADMIN_HTTP_ADDRESS", "localhost:2000")
+
+		cmd := NewServerCommand("admin", &testAdminServer{}, WithEnvPrefix("ADMIN"))
+
+		out := executeCommand(t, cmd, "config", "print")
+		require.Contains(t, out, "http-address: localhost:2000 # env\n")
+	})
+
+	t.Run("running a single task", func(t *testing.T) {
+		out := executeCommand(t, NewServerCommand("api", &testAPIServer{}), "run-task", "--list")
+		require.Equal(t, "github.com/tscolari/servicetools/cmd.(*testAPIServer).ConfigureWorker.func1\nsucceed\nfail\n", out)
+
+		executeCommand(t, NewServerCommand("api", &testAPIServer{}), "run-task", "succeed", "--log-output", "stderr")
+
+		cmd := NewServerCommand("api", &testAPIServer{})
+		cmd.SetArgs([]string{"run-task", "fail", "--log-output", "stderr"})
+		require.ErrorContains(t, cmd.Execute(), "task failed")
+
+		cmd.SetArgs([]string{"run-task", "missing", "--log-output", "stderr"})
+		require.ErrorIs(t, cmd.Execute(), server.ErrUnknownTask)
+	})
+
+	t.Run("run-task requires the worker capability", func(t *testing.T) {
+		cmd := NewServerCommand("admin", &testAdminServer{})
+
+		for _, subCmd := range cmd.Commands() {
+			require.NotEqual(t, "run-task", subCmd.Name())
+		}
+	})
+
+	t.Run("TLS flags", func(t *testing.T) {
+		cmd := NewServerCommand("admin", &testAdminServer{})
+		require.NotNil(t, cmd.PersistentFlags().Lookup("http-tls-client-ca"))
+
+		// The certificates are only loaded by the server, which starts the listeners.
+		out := executeCommand(t, cmd, "describe", "--http-tls-cert", "missing.pem")
+		require.Contains(t, out, "/admin")
+
+		executeCommand(t, NewServerCommand("api", &testAPIServer{}), "run-task", "succeed", "--log-output", "stderr", "--http-tls-cert", "missing.pem")
+
+		cmd = NewServerCommand("admin", &testAdminServer{})
+		cmd.SetArgs([]string{"--http-tls-cert", "missing.pem", "--log-output", "stderr"})
+		require.ErrorContains(t, cmd.Execute(), "failed to configure TLS for http")
+	})
+
+	t.Run("CanServer", func(t *testing.T) {
+		rootCmd := &cobra.Command{Use: "test"}
+		CanServer(rootCmd, &testAdminServer{})
+
+		out := executeCommand(t, rootCmd, "describe", "--http-address", "localhost:1000")
+		require.Contains(t, out, "/admin")
+
+		for _, name := range []string{"server", "start", "config", "describe", "version"} {
+			cmd, _, err := rootCmd.Find([]string{name})
+			require.NoError(t, err)
+			require.NotEqual(t, rootCmd, cmd)
+		}
+	})
+}
+
+// grpcFlags returns the flags of the grpc capability, parsed from the given args.
+func grpcFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	for _, capability := range builtinCapabilities {
+		if capability.Name == "grpc" {
+			capability.Flags(flags)
+		}
+	}
+
+	require.NoError(t, flags.Parse(args))
+	return flags
+}
+
+func Test_ShareGRPCListener(t *testing.T) {
+	components := func() []server.Component {
+		return []server.Component{server.NewWithHTTP("localhost:0"), server.NewWithGRPC("localhost:0")}
+	}
+
+	t.Run("sharing the HTTP listener", func(t *testing.T) {
+		require.NoError(t, shareGRPCListener(grpcFlags(t, "--grpc-on-http"), components()))
+	})
+
+	t.Run("without the http capability", func(t *testing.T) {
+		err := shareGRPCListener(grpcFlags(t, "--grpc-on-http"), []server.Component{server.NewWithGRPC("localhost:0")})
+		require.ErrorContains(t, err, "requires both the grpc and http capabilities")
+	})
+
+	t.Run("with gRPC TLS flags", func(t *testing.T) {
+		flags := grpcFlags(t, "--grpc-on-http", "--grpc-tls-cert", "cert.pem", "--grpc-tls-key", "key.pem")
+		require.ErrorContains(t, shareGRPCListener(flags, components()), "--grpc-on-http can't be used with --grpc-tls-cert")
+
+		flags = grpcFlags(t, "--grpc-on-http", "--grpc-tls-client-ca", "ca.pem")
+		require.ErrorContains(t, shareGRPCListener(flags, components()), "--grpc-tls-client-ca")
+
+		// Without --grpc-on-http, the gRPC server terminates TLS itself.
+		require.NoError(t, shareGRPCListener(grpcFlags(t, "--grpc-tls-cert", "cert.pem"), components()))
+	})
+}
+
+func Test_InstrumentGRPC(t *testing.T) {
+	t.Run("without metrics capability", func(t *testing.T) {
+		withGRPC := server.NewWithGRPC("localhost:0")
+		require.NoError(t, instrumentGRPC(grpcFlags(t), []server.Component{withGRPC}))
+	})
+
+	t.Run("records the RPCs in the metrics server", func(t *testing.T) {
+		withGRPC := server.NewWithGRPC("localhost:0")
+		withGRPC.Register(func(r grpc.ServiceRegistrar) {
+			grpc_health_v1.RegisterHealthServer(r, health.NewServer())
+		})
+		withMetrics := server.NewWithMetrics("localhost:0")
+
+		flags := grpcFlags(t, "--grpc-metrics-exclude", "/grpc.health.v1.Health/Watch")
+		require.NoError(t, instrumentGRPC(flags, []server.Component{withMetrics, withGRPC}))
+
+		listener, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		withGRPC.UseListener(listener)
+
+		go func() {
+			require.NoError(t, withGRPC.Start(context.Background(), slog.Default()))
+		}()
+		<-withGRPC.Ready()
+		defer func() {
+			require.NoError(t, withGRPC.Stop(context.Background(), slog.Default()))
+		}()
+
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		families, err := withMetrics.Registerer().(prometheus.Gatherer).Gather()
+		require.NoError(t, err)
+
+		var handled []string
+		for _, family := range families {
+			if family.GetName() == "grpc_server_handled_total" {
+				for _, metric := range family.GetMetric() {
+					for _, label := range metric.GetLabel() {
+						if label.GetName() == "grpc_method" {
+							handled = append(handled, label.GetValue())
+						}
+					}
+				}
+			}
+		}
+
+		require.Equal(t, []string{"Check"}, handled)
+	})
+
+	t.Run("records recovered panics in the metrics server", func(t *testing.T) {
+		withGRPC := server.NewWithGRPC("localhost:0")
+		withGRPC.Register(func(r grpc.ServiceRegistrar) {
+			grpc_health_v1.RegisterHealthServer(r, panickingHealthServer{})
+		})
+		withMetrics := server.NewWithMetrics("localhost:0")
+
+		require.NoError(t, instrumentGRPC(grpcFlags(t), []server.Component{withMetrics, withGRPC}))
+
+		// Other servers have their own metrics.
+		other := server.NewWithGRPC("localhost:0")
+		require.NoError(t, instrumentGRPC(grpcFlags(t), []server.Component{server.NewWithMetrics("localhost:0"), other}))
+
+		listener, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		withGRPC.UseListener(listener)
+
+		go func() {
+			require.NoError(t, withGRPC.Start(context.Background(), slog.Default()))
+		}()
+		<-withGRPC.Ready()
+		defer func() {
+			require.NoError(t, withGRPC.Stop(context.Background(), slog.Default()))
+		}()
+
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.Equal(t, codes.Internal, status.Code(err))
+
+		families, err := withMetrics.Registerer().(prometheus.Gatherer).Gather()
+		require.NoError(t, err)
+
+		var panics float64
+		for _, family := range families {
+			if family.GetName() == "grpc_server_panics_recovered_total" {
+				for _, metric := range family.GetMetric() {
+					panics += metric.GetCounter().GetValue()
+				}
+			}
+		}
+
+		require.Equal(t, float64(1), panics)
+	})
+}
+
+type panickingHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (panickingHealthServer) Check(context.Context, *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	panic("check failed")
+}
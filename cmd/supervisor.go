@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tscolari/servicetools/server"
+)
+
+// errNotReady is returned by the supervisor readiness check while
+// not all components have started.
+var errNotReady = errors.New("not all components are ready")
+
+// supervisor starts a set of components concurrently, and stops them
+// in the reverse order that they were added.
+// Components should be added in dependency order, that is, a component
+// should be added after all the components that it depends on.
+type supervisor struct {
+	components []server.Component
+	ready      atomic.Bool
+
+	// exited has a channel for each component, closed if its Start returns nil.
+	exited []chan struct{}
+
+	// drainDelay is how long the supervisor waits, reporting not-ready but
+	// still serving, before stopping the components.
+	drainDelay time.Duration
+
+	// shutdownTimeout limits how long stopping the components can take.
+	// Components that are still stopping when it expires are stopped forcefully.
+	// Zero means no limit.
+	shutdownTimeout time.Duration
+
+	// onStarted is called once all components have started.
+	onStarted func(context.Context, *slog.Logger) error
+
+	// onStop is called before the components are stopped.
+	onStop func(context.Context, *slog.Logger) error
+}
+
+// add appends a component to be managed by the supervisor.
+func (s *supervisor) add(c server.Component) {
+	s.components = append(s.components, c)
+	s.exited = append(s.exited, make(chan struct{}))
+}
+
+// readinessCheck fails until all components have started.
+// It's compatible with healthcheck.Check.
+func (s *supervisor) readinessCheck() error {
+	if !s.ready.Load() {
+		return errNotReady
+	}
+
+	return nil
+}
+
+// runningCheck returns a readiness check that fails unless the component with the
+// given index is running, or has exited cleanly (see run).
+// It's compatible with healthcheck.Check.
+func (s *supervisor) runningCheck(i int) func() error {
+	c, exited := s.components[i], s.exited[i]
+
+	return func() error {
+		select {
+		case <-exited:
+			return nil
+		default:
+		}
+
+		if state := c.State(); state != server.StateRunning {
+			return fmt.Errorf("component is %s", state)
+		}
+
+		return nil
+	}
+}
+
+// run starts all components concurrently and blocks until the given context
+// is done or any of the components fails.
+// A component whose Start returns nil, e.g. a worker whose tasks are all done, is
+// finished: the others keep running, and it's no longer waited for to be ready,
+// nor checked by runningCheck.
+// Either way, all components are stopped in the reverse order that they were added,
+// after the drain delay if the context is done, and the error that caused the
+// supervisor to stop is returned.
+// The components are started with a context that is only canceled once they were
+// all stopped, so that they keep running, e.g. for in-flight requests, until then.
+func (s *supervisor) run(ctx context.Context, logger *slog.Logger) error {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	// One extra slot for the onStarted hook.
+	errs := make(chan error, len(s.components)+1)
+	wg := new(sync.WaitGroup)
+
+	for i, c := range s.components {
+		wg.Add(1)
+		go func(c server.Component, exited chan struct{}) {
+			defer wg.Done()
+
+			if err := c.Start(runCtx, logger); err != nil {
+				errs <- fmt.Errorf("component %s failed: %w", c.Name(), err)
+				return
+			}
+
+			close(exited)
+			logger.Info("component exited", "component", c.Name())
+		}(c, s.exited[i])
+	}
+
+	allStarted := make(chan struct{})
+	go func() {
+		for i, c := range s.components {
+			select {
+			case <-c.Ready():
+			case <-s.exited[i]:
+			case <-runCtx.Done():
+				return
+			}
+		}
+
+		close(allStarted)
+	}()
+
+	var err error
+
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	case <-allStarted:
+		logger.Info("all components started")
+		s.ready.Store(true)
+
+		if s.onStarted != nil {
+			go func() {
+				if err := s.onStarted(runCtx, logger); err != nil {
+					errs <- fmt.Errorf("server start failed: %w", err)
+				}
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+		case err = <-errs:
+		}
+	}
+
+	// Draining only makes sense when stopping on request, not when a component failed.
+	if wasReady := s.ready.Swap(false); wasReady && err == nil && s.drainDelay > 0 {
+		logger.Info("draining before stopping components", "drain_delay", s.drainDelay.String())
+		time.Sleep(s.drainDelay)
+	}
+
+	stopCtx := context.WithoutCancel(ctx)
+	if s.shutdownTimeout > 0 {
+		var cancelStop context.CancelFunc
+		stopCtx, cancelStop = context.WithTimeout(stopCtx, s.shutdownTimeout)
+		defer cancelStop()
+	}
+
+	s.stop(stopCtx, logger)
+	cancel()
+
+	exited := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+	case <-stopCtx.Done():
+		logger.Error("shutdown timed out, not all components exited")
+	}
+
+	return err
+}
+
+// stop calls the onStop hook and stops all components in reverse order.
+func (s *supervisor) stop(ctx context.Context, logger *slog.Logger) {
+	if s.onStop != nil {
+		if err := s.onStop(ctx, logger); err != nil {
+			logger.Error("attempt to stop server failed", "error", err)
+		}
+	}
+
+	for i := len(s.components) - 1; i >= 0; i-- {
+		c := s.components[i]
+
+		logger.Info("stopping component", "component", c.Name(), "state", c.State().String())
+		if err := c.Stop(ctx, logger); err != nil && !errors.Is(err, server.ErrNotStarted) {
+			logger.Error("failed to stop component", "component", c.Name(), "error", err)
+		}
+	}
+}
+
+// close releases the resources held by components that were never started,
+// e.g. when the configuration of another component fails.
+func (s *supervisor) close(logger *slog.Logger) {
+	for i := len(s.components) - 1; i >= 0; i-- {
+		if closer, ok := s.components[i].(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Error("failed to close component", "component", s.components[i].Name(), "error", err)
+			}
+		}
+	}
+}
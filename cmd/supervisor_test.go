@@ -0,0 +1,437 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tscolari/servicetools/server"
+	"github.com/tscolari/servicetools/testhelpers"
+)
+
+// testComponent blocks on start until it's stopped, or its context is done,
+// and records the order in which it was stopped.
+type testComponent struct {
+	name     string
+	startErr error
+	// hangOnStop makes Stop block until its context is done.
+	hangOnStop bool
+	// startDelay delays it being ready.
+	startDelay time.Duration
+	// exitOnStart makes Start return nil right away, without ever being ready.
+	exitOnStart bool
+	// failures makes Start return the error sent to it, once it's running.
+	failures chan error
+
+	started chan struct{}
+	stopped chan struct{}
+	// exited is closed when Start returns without being stopped.
+	exited chan struct{}
+	order  *stopOrder
+}
+
+type stopOrder struct {
+	mutex *sync.Mutex
+	names []string
+}
+
+func (o *stopOrder) add(name string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.names = append(o.names, name)
+}
+
+func newTestComponent(name string, order *stopOrder) *testComponent {
+	return &testComponent{
+		name:    name,
+		started: make(chan struct{}),
+		stopped: make(chan struct{}),
+		exited:  make(chan struct{}),
+		order:   order,
+	}
+}
+
+func (c *testComponent) Name() string {
+	return c.name
+}
+
+func (c *testComponent) Start(ctx context.Context, logger *slog.Logger) error {
+	if c.startErr != nil {
+		return c.startErr
+	}
+
+	if c.exitOnStart {
+		return nil
+	}
+
+	time.Sleep(c.startDelay)
+	close(c.started)
+
+	select {
+	case <-c.stopped:
+	case <-ctx.Done():
+		close(c.exited)
+	case err := <-c.failures:
+		close(c.exited)
+		return err
+	}
+
+	return nil
+}
+
+func (c *testComponent) Stop(ctx context.Context, logger *slog.Logger) error {
+	if c.State() != server.StateRunning {
+		return server.ErrNotStarted
+	}
+
+	c.order.add(c.name)
+
+	if c.hangOnStop {
+		<-ctx.Done()
+		close(c.stopped)
+		return ctx.Err()
+	}
+
+	close(c.stopped)
+	return nil
+}
+
+func (c *testComponent) Ready() <-chan struct{} {
+	return c.started
+}
+
+func (c *testComponent) State() server.State {
+	// Stopped components were started too, so that is checked first.
+	select {
+	case <-c.stopped:
+		return server.StateStopped
+	case <-c.exited:
+		return server.StateStopped
+	default:
+	}
+
+	select {
+	case <-c.started:
+		return server.StateRunning
+	default:
+		return server.StateNew
+	}
+}
+
+func Test_Supervisor(t *testing.T) {
+	t.Run("components are stopped in reverse order", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		first := newTestComponent("first", order)
+		second := newTestComponent("second", order)
+		third := newTestComponent("third", order)
+
+		s := &supervisor{}
+		s.add(first)
+		s.add(second)
+		s.add(third)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			errChan <- s.run(ctx, slog.Default())
+		}()
+
+		require.Eventually(t, func() bool {
+			return s.readinessCheck() == nil
+		}, 500*time.Millisecond, 10*time.Millisecond)
+
+		cancel()
+
+		select {
+		case err := <-errChan:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for supervisor to stop")
+		}
+
+		require.Equal(t, []string{"third", "second", "first"}, order.names)
+		require.ErrorIs(t, s.readinessCheck(), errNotReady)
+	})
+
+	t.Run("components keep running until they are stopped", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		first := newTestComponent("first", order)
+		second := newTestComponent("second", order)
+
+		var statesOnStop []server.State
+		s := &supervisor{
+			onStop: func(context.Context, *slog.Logger) error {
+				statesOnStop = append(statesOnStop, first.State(), second.State())
+				return nil
+			},
+		}
+		s.add(first)
+		s.add(second)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			errChan <- s.run(ctx, slog.Default())
+		}()
+
+		require.Eventually(t, func() bool {
+			return s.readinessCheck() == nil
+		}, 500*time.Millisecond, 10*time.Millisecond)
+
+		cancel()
+
+		select {
+		case err := <-errChan:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for supervisor to stop")
+		}
+
+		require.Equal(t, []server.State{server.StateRunning, server.StateRunning}, statesOnStop)
+		require.Equal(t, []string{"second", "first"}, order.names)
+	})
+
+	t.Run("components that return right away", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		// The worker returns before the supervisor waits for it to be ready.
+		first := newTestComponent("first", order)
+		first.startDelay = 50 * time.Millisecond
+
+		s := &supervisor{}
+		s.add(first)
+		// A worker without tasks returns as soon as it's started.
+		s.add(server.NewWithWorker())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			errChan <- s.run(ctx, slog.Default())
+		}()
+
+		require.Eventually(t, func() bool {
+			return s.readinessCheck() == nil
+		}, 500*time.Millisecond, 10*time.Millisecond)
+
+		cancel()
+		require.NoError(t, <-errChan)
+	})
+
+	t.Run("components that exit cleanly are finished", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		first := newTestComponent("first", order)
+		exiting := newTestComponent("exiting", order)
+		exiting.exitOnStart = true
+
+		s := &supervisor{}
+		s.add(first)
+		s.add(exiting)
+
+		require.Error(t, s.runningCheck(1)())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			errChan <- s.run(ctx, slog.Default())
+		}()
+
+		require.Eventually(t, func() bool {
+			return s.readinessCheck() == nil
+		}, 500*time.Millisecond, 10*time.Millisecond)
+
+		// The others keep running, and the finished one is no longer checked.
+		require.NoError(t, s.runningCheck(0)())
+		require.NoError(t, s.runningCheck(1)())
+
+		cancel()
+		require.NoError(t, <-errChan)
+		require.Equal(t, []string{"first"}, order.names)
+	})
+
+	t.Run("a failing component stops the others", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		first := newTestComponent("first", order)
+		failing := newTestComponent("failing", order)
+		failing.startErr = errors.New("boom")
+
+		s := &supervisor{}
+		s.add(first)
+		s.add(failing)
+
+		errChan := make(chan error)
+		go func() {
+			errChan <- s.run(context.Background(), slog.Default())
+		}()
+
+		select {
+		case err := <-errChan:
+			require.ErrorIs(t, err, failing.startErr)
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for supervisor to stop")
+		}
+
+		require.Equal(t, []string{"first"}, order.names)
+	})
+
+	t.Run("hooks are called", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		first := newTestComponent("first", order)
+
+		hookCalled := make(chan struct{})
+		startHook := NewMockHasStartHook(t)
+		startHook.On("OnStart", mock.Anything, mock.Anything).Return(nil).Run(func(mock.Arguments) {
+			close(hookCalled)
+		}).Once()
+
+		stopHook := NewMockHasStopHook(t)
+		stopHook.On("OnStop", mock.Anything, mock.Anything).Return(nil).Run(func(mock.Arguments) {
+			order.add("hook")
+		}).Once()
+
+		s := &supervisor{
+			onStarted: startHook.OnStart,
+			onStop:    stopHook.OnStop,
+		}
+		s.add(first)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			errChan <- s.run(ctx, slog.Default())
+		}()
+
+		select {
+		case <-hookCalled:
+		case <-time.After(500 * time.Millisecond):
+			require.Fail(t, "timed out waiting for start hook")
+		}
+
+		cancel()
+		require.NoError(t, <-errChan)
+		require.Equal(t, []string{"hook", "first"}, order.names)
+	})
+
+	t.Run("a failing start hook stops the components", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		first := newTestComponent("first", order)
+
+		hookErr := errors.New("hook failed")
+		s := &supervisor{
+			onStarted: func(context.Context, *slog.Logger) error { return hookErr },
+		}
+		s.add(first)
+
+		err := s.run(context.Background(), slog.Default())
+		require.ErrorIs(t, err, hookErr)
+		require.Equal(t, []string{"first"}, order.names)
+	})
+
+	t.Run("draining before stopping", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		// The dependency exits as soon as its context is done, like the database and worker components.
+		dependency := newTestComponent("dependency", order)
+		first := newTestComponent("first", order)
+
+		s := &supervisor{drainDelay: 200 * time.Millisecond}
+		s.add(dependency)
+		s.add(first)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			errChan <- s.run(ctx, slog.Default())
+		}()
+
+		require.Eventually(t, func() bool {
+			return s.readinessCheck() == nil
+		}, 500*time.Millisecond, 10*time.Millisecond)
+
+		cancel()
+
+		require.Eventually(t, func() bool {
+			return errors.Is(s.readinessCheck(), errNotReady)
+		}, 100*time.Millisecond, 5*time.Millisecond)
+
+		testhelpers.Constantly(t, func() bool {
+			return first.State() == server.StateRunning && dependency.State() == server.StateRunning
+		}, 150*time.Millisecond, 10*time.Millisecond, "component stopped while draining")
+
+		select {
+		case err := <-errChan:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for supervisor to stop")
+		}
+
+		require.Equal(t, []string{"first", "dependency"}, order.names)
+	})
+
+	t.Run("not draining when a component fails", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		first := newTestComponent("first", order)
+		failing := newTestComponent("failing", order)
+		failing.failures = make(chan error, 1)
+
+		s := &supervisor{drainDelay: time.Minute}
+		s.add(first)
+		s.add(failing)
+
+		errChan := make(chan error)
+		go func() {
+			errChan <- s.run(context.Background(), slog.Default())
+		}()
+
+		require.Eventually(t, func() bool {
+			return s.readinessCheck() == nil
+		}, 500*time.Millisecond, 10*time.Millisecond)
+
+		failure := errors.New("crashed")
+		failing.failures <- failure
+
+		select {
+		case err := <-errChan:
+			require.ErrorIs(t, err, failure)
+		case <-time.After(time.Second):
+			require.Fail(t, "supervisor drained after a failure")
+		}
+
+		require.Equal(t, []string{"first"}, order.names)
+	})
+
+	t.Run("shutdown timeout", func(t *testing.T) {
+		order := &stopOrder{mutex: new(sync.Mutex)}
+		first := newTestComponent("first", order)
+		hanging := newTestComponent("hanging", order)
+		hanging.hangOnStop = true
+
+		s := &supervisor{shutdownTimeout: 100 * time.Millisecond}
+		s.add(first)
+		s.add(hanging)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			errChan <- s.run(ctx, slog.Default())
+		}()
+
+		require.Eventually(t, func() bool {
+			return s.readinessCheck() == nil
+		}, 500*time.Millisecond, 10*time.Millisecond)
+
+		cancel()
+
+		select {
+		case err := <-errChan:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for supervisor to stop")
+		}
+
+		require.Equal(t, []string{"hanging", "first"}, order.names)
+	})
+}
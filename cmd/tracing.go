@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/spf13/pflag"
+
+	"github.com/tscolari/servicetools/tracing"
+)
+
+// tracingFlags adds the flags that configure the exporter of the spans.
+func tracingFlags(flags *pflag.FlagSet) {
+	flags.String("trace-output", "", "file to append the finished spans to, as JSON lines, empty to not export them")
+}
+
+// setupTracing sets the span exporter configured by the tracing flags,
+// reporting the spans it fails to export with the given logger.
+// The returned closer must be called once the spans are no longer exported.
+func setupTracing(flags *pflag.FlagSet, logger *slog.Logger) (func() error, error) {
+	path, _ := flags.GetString("trace-output")
+	if path == "" {
+		tracing.SetExporter(nil)
+		return func() error { return nil }, nil
+	}
+
+	exporter, err := tracing.NewFileExporter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tracing.SetExporter(loggedExporter{Exporter: exporter, logger: logger})
+
+	return func() error {
+		tracing.SetExporter(nil)
+		return exporter.Close()
+	}, nil
+}
+
+// loggedExporter logs the failures of the wrapped exporter with the configured logger,
+// instead of the default one used by the tracing package.
+type loggedExporter struct {
+	tracing.Exporter
+	logger *slog.Logger
+}
+
+func (e loggedExporter) Export(span tracing.SpanData) error {
+	if err := e.Exporter.Export(span); err != nil {
+		e.logger.Warn("failed to export span", "span", span.Name, "error", err)
+	}
+
+	return nil
+}
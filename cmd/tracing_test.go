@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tscolari/servicetools/tracing"
+)
+
+type failingExporter struct{}
+
+func (failingExporter) Export(tracing.SpanData) error {
+	return errors.New("export failed")
+}
+
+func Test_LoggedExporter(t *testing.T) {
+	out := &bytes.Buffer{}
+	exporter := loggedExporter{
+		Exporter: failingExporter{},
+		logger:   slog.New(slog.NewTextHandler(out, nil)),
+	}
+
+	// Failures are logged with the configured logger.
+	require.NoError(t, exporter.Export(tracing.SpanData{Name: "span"}))
+	require.Contains(t, out.String(), `msg="failed to export span"`)
+	require.Contains(t, out.String(), `span=span`)
+	require.Contains(t, out.String(), `error="export failed"`)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tscolari/servicetools/buildinfo"
+)
+
+// CanVersion injects the "version" subcommand to another command.
+// It's already injected by CanServer.
+func CanVersion(rootCmd *cobra.Command) {
+	rootCmd.AddCommand(NewVersionCommand())
+}
+
+// NewVersionCommand returns the command that prints the build of the binary:
+// the module version, VCS revision and time, dirty flag and Go version.
+// See the buildinfo package for setting them with ldflags.
+func NewVersionCommand() *cobra.Command {
+	var output string
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Prints the version of the build",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := buildinfo.Read()
+			out := cmd.OutOrStdout()
+
+			switch output {
+			case "json":
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(info)
+
+			case "text":
+				fmt.Fprintf(out, "version: %s\n", info.Version)
+				fmt.Fprintf(out, "revision: %s\n", info.Revision)
+				fmt.Fprintf(out, "time: %s\n", info.Time)
+				fmt.Fprintf(out, "dirty: %t\n", info.Dirty)
+				fmt.Fprintf(out, "go: %s\n", info.GoVersion)
+				return nil
+			}
+
+			return fmt.Errorf("invalid output format %q", output)
+		},
+	}
+
+	versionCmd.Flags().StringVarP(&output, "output", "o", "text", "output format: text or json")
+
+	return versionCmd
+}
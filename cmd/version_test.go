@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tscolari/servicetools/buildinfo"
+)
+
+func Test_Version(t *testing.T) {
+	t.Run("text output", func(t *testing.T) {
+		out := executeCommand(t, NewVersionCommand())
+		require.Contains(t, out, "go: "+runtime.Version()+"\n")
+	})
+
+	t.Run("json output", func(t *testing.T) {
+		out := executeCommand(t, NewVersionCommand(), "-o", "json")
+
+		var info buildinfo.Info
+		require.NoError(t, json.Unmarshal([]byte(out), &info))
+		require.Equal(t, buildinfo.Read(), info)
+	})
+}
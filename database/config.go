@@ -16,6 +16,17 @@ const (
 	envPassword = "PASSWORD"
 	envDBName   = "NAME"
 	envSSLMode  = "SSLMODE"
+
+	envMaxIdleConns    = "MAX_IDLE_CONNS"
+	envMaxOpenConns    = "MAX_OPEN_CONNS"
+	envConnMaxIdleTime = "CONN_MAX_IDLE_TIME"
+	envConnMaxLifeTime = "CONN_MAX_LIFE_TIME"
+
+	envConnectTimeout          = "CONNECT_TIMEOUT"
+	envConnectRetryInterval    = "CONNECT_RETRY_INTERVAL"
+	envConnectRetryMaxInterval = "CONNECT_RETRY_MAX_INTERVAL"
+
+	envTraceQueries = "TRACE_QUERIES"
 )
 
 // ErrNoEnvConfiguration is used when a configuration can't be created
@@ -35,6 +46,19 @@ type Config struct {
 	MaxOpenConns    int           `json:"max_open_conns,omitempty"`
 	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time,omitempty"`
 	ConnMaxLifeTime time.Duration `json:"conn_max_life_time,omitempty"`
+
+	// ConnectTimeout is how long Open keeps retrying to connect to the database.
+	// If 0, Open gives up after the first attempt.
+	ConnectTimeout time.Duration `json:"connect_timeout,omitempty"`
+	// ConnectRetryInterval is the initial wait between connection attempts.
+	// It doubles on every attempt, up to ConnectRetryMaxInterval.
+	ConnectRetryInterval time.Duration `json:"connect_retry_interval,omitempty"`
+	// ConnectRetryMaxInterval is the maximum wait between connection attempts.
+	ConnectRetryMaxInterval time.Duration `json:"connect_retry_max_interval,omitempty"`
+
+	// TraceQueries makes Open wrap the driver with WithTracing, so that queries are traced.
+	// The driver connections given to sql.Conn.Raw are then wrapped too, see UnwrapConn.
+	TraceQueries bool `json:"trace_queries,omitempty"`
 }
 
 // ConfigFromEnv loads the database configuration from env variables
@@ -60,6 +84,35 @@ func ConfigFromEnv(prefix string) (*Config, error) {
 
 	config.Port = port
 
+	config.TraceQueries = os.Getenv(fmt.Sprintf("%s_%s", prefix, envTraceQueries)) == "true"
+
+	// Connection pool settings are optional.
+	if value := os.Getenv(fmt.Sprintf("%s_%s", prefix, envMaxIdleConns)); value != "" {
+		if config.MaxIdleConns, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", envMaxIdleConns, err)
+		}
+	}
+
+	if value := os.Getenv(fmt.Sprintf("%s_%s", prefix, envMaxOpenConns)); value != "" {
+		if config.MaxOpenConns, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", envMaxOpenConns, err)
+		}
+	}
+
+	for env, value := range map[string]*time.Duration{
+		envConnMaxIdleTime:         &config.ConnMaxIdleTime,
+		envConnMaxLifeTime:         &config.ConnMaxLifeTime,
+		envConnectTimeout:          &config.ConnectTimeout,
+		envConnectRetryInterval:    &config.ConnectRetryInterval,
+		envConnectRetryMaxInterval: &config.ConnectRetryMaxInterval,
+	} {
+		if raw := os.Getenv(fmt.Sprintf("%s_%s", prefix, env)); raw != "" {
+			if *value, err = time.ParseDuration(raw); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+		}
+	}
+
 	return &config, nil
 }
 
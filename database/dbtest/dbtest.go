@@ -3,6 +3,7 @@ package dbtest
 import (
 	"database/sql"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
@@ -10,9 +11,6 @@ import (
 	_ "github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/require"
 	"github.com/tscolari/servicetools/database"
-
-	// used because the source of the migration is a file.
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
 const (
@@ -72,13 +70,29 @@ func init() {
 // this to be used in parallel tests, unless they are using different
 // database names.
 func DB(t *testing.T, migrationsPath, name string) (*sql.DB, func()) {
+	return openDB(t, name, func(t *testing.T, db *sql.DB) {
+		if migrationsPath != "" {
+			migrateDB(t, db, migrationsPath)
+		}
+	})
+}
 
+// DBFS is the same as DB, but the migrations are read from the dir of the
+// given filesystem (e.g. an embed.FS), so that the tests don't depend on the
+// working directory.
+func DBFS(t *testing.T, fsys fs.FS, dir, name string) (*sql.DB, func()) {
+	return openDB(t, name, func(t *testing.T, db *sql.DB) {
+		require.NoError(t, database.MigrateFS(db, fsys, dir))
+	})
+}
+
+func openDB(t *testing.T, name string, migrate func(*testing.T, *sql.DB)) (*sql.DB, func()) {
 	name = name + Config.DBSuffix
 
 	var db *sql.DB
 
 	if !isDBInitialized(name) {
-		db = initializeDB(t, migrationsPath, name)
+		db = initializeDB(t, name, migrate)
 
 	} else {
 		var err error
@@ -103,7 +117,7 @@ func isDBInitialized(name string) bool {
 	return ok
 }
 
-func initializeDB(t *testing.T, migrationsPath, name string) *sql.DB {
+func initializeDB(t *testing.T, name string, migrate func(*testing.T, *sql.DB)) *sql.DB {
 	connStr := connectionString(Config.Username, Config.Password, Config.RootDBName)
 	db, err := sql.Open("postgres", connStr)
 	require.NoError(t, err, "failed to open DB connection")
@@ -122,9 +136,7 @@ func initializeDB(t *testing.T, migrationsPath, name string) *sql.DB {
 	db, err = sql.Open("postgres", connStr)
 	require.NoError(t, err, "failed to open DB connection")
 
-	if migrationsPath != "" {
-		migrateDB(t, db, migrationsPath)
-	}
+	migrate(t, db)
 
 	return db
 }
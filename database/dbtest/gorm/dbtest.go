@@ -1,6 +1,7 @@
 package gorm
 
 import (
+	"io/fs"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -18,3 +19,13 @@ func DB(t *testing.T, migrationsPath, name string) (*gorm.DB, func()) {
 
 	return gormDB, closer
 }
+
+// DBFS is a utility wrapper around dbtest.DBFS to return a gorm.DB object.
+func DBFS(t *testing.T, fsys fs.FS, dir, name string) (*gorm.DB, func()) {
+	db, closer := dbtest.DBFS(t, fsys, dir, name)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}))
+	require.NoError(t, err)
+
+	return gormDB, closer
+}
@@ -1,34 +1,342 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	migratepg "github.com/golang-migrate/migrate/v4/database/postgres"
-
-	// used because the source of the migration is a file.
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
+// migrationTimeFormat is the format of the version of migrations created with CreateMigration.
+const migrationTimeFormat = "20060102150405"
+
+// migrationLockID is the key of the Postgres advisory lock held by MigrateWithLock.
+const migrationLockID = 4_531_927_302
+
+var migrationNameReplacer = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ErrUnknownSchemaVersion is returned by MigrateWithLock when the database
+// version is newer than the latest known migration.
+var ErrUnknownSchemaVersion = errors.New("database schema version is newer than the known migrations")
+
+// MigrationStatus describes the state of the database migrations.
+type MigrationStatus struct {
+	// Version is the current version of the database, 0 if no migration was applied.
+	Version uint `json:"version"`
+
+	// Dirty is true if the last migration failed, and the database
+	// needs to be fixed manually (see ForceMigration).
+	Dirty bool `json:"dirty"`
+
+	// Latest is the version of the last known migration.
+	Latest uint `json:"latest"`
+
+	// Pending lists the migrations that were not applied yet, as "<version>_<name>".
+	Pending []string `json:"pending"`
+}
+
 // Migrate performs the database migration using the given database connection
 // and migrations path.
 func Migrate(db *sql.DB, migrationsPath string) error {
+	return MigrateFS(db, os.DirFS(migrationsPath), ".")
+}
 
-	driver, err := migratepg.WithInstance(db, &migratepg.Config{})
+// MigrateFS is the same as Migrate, but reads the migrations from the dir of
+// the given filesystem, e.g. an embed.FS.
+func MigrateFS(db *sql.DB, fsys fs.FS, dir string) error {
+	migrator, err := newMigrator(db, fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	if err := migrator.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to run migrations: %v", err)
+	}
+
+	return nil
+}
+
+// MigrateDown rolls back the given number of migrations.
+// If steps is 0 or less, all migrations are rolled back.
+func MigrateDown(db *sql.DB, migrationsPath string, steps int) error {
+	return MigrateDownFS(db, os.DirFS(migrationsPath), ".", steps)
+}
+
+// MigrateDownFS is the same as MigrateDown, but reads the migrations from the dir of
+// the given filesystem.
+func MigrateDownFS(db *sql.DB, fsys fs.FS, dir string, steps int) error {
+	migrator, err := newMigrator(db, fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	if steps > 0 {
+		err = migrator.Steps(-steps)
+	} else {
+		err = migrator.Down()
+	}
+
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to roll back migrations: %w", err)
+	}
+
+	return nil
+}
+
+// MigrateTo migrates the database up or down to the given version.
+func MigrateTo(db *sql.DB, migrationsPath string, version uint) error {
+	return MigrateToFS(db, os.DirFS(migrationsPath), ".", version)
+}
+
+// MigrateToFS is the same as MigrateTo, but reads the migrations from the dir of
+// the given filesystem.
+func MigrateToFS(db *sql.DB, fsys fs.FS, dir string, version uint) error {
+	migrator, err := newMigrator(db, fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	if err := migrator.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// ForceMigration sets the database version without running any migrations,
+// clearing the dirty flag. It's meant to recover from a failed migration,
+// after the database was fixed manually.
+// A version of -1 means that no migration was applied.
+func ForceMigration(db *sql.DB, migrationsPath string, version int) error {
+	return ForceMigrationFS(db, os.DirFS(migrationsPath), ".", version)
+}
+
+// ForceMigrationFS is the same as ForceMigration, but reads the migrations from the dir of
+// the given filesystem.
+func ForceMigrationFS(db *sql.DB, fsys fs.FS, dir string, version int) error {
+	migrator, err := newMigrator(db, fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	if err := migrator.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// ReadMigrationStatus returns the current version of the database and
+// the migrations from the given path that are pending.
+func ReadMigrationStatus(db *sql.DB, migrationsPath string) (*MigrationStatus, error) {
+	return ReadMigrationStatusFS(db, os.DirFS(migrationsPath), ".")
+}
+
+// ReadMigrationStatusFS is the same as ReadMigrationStatus, but reads the migrations
+// from the dir of the given filesystem.
+func ReadMigrationStatusFS(db *sql.DB, fsys fs.FS, dir string) (*MigrationStatus, error) {
+	migrator, err := newMigrator(db, fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return readMigrationStatus(migrator, fsys, dir)
+}
+
+// MigrateWithLock is the same as MigrateFS, but holds a Postgres advisory lock while
+// migrating, so that concurrent callers (e.g. replicas of the same service) wait for
+// each other instead of racing.
+// It fails with ErrUnknownSchemaVersion if the database is at a newer version than
+// the given migrations know about, and refuses to migrate a dirty database.
+// Canceling the context stops the migration after the one currently running.
+func MigrateWithLock(ctx context.Context, db *sql.DB, fsys fs.FS, dir string) (err error) {
+	// The lock is bound to the session, so the same connection is used to migrate.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		// The lock must be released even if the context was canceled,
+		// otherwise it would be kept by the connection returning to the pool.
+		_, unlockErr := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockID)
+		if unlockErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to release migration lock: %w", unlockErr))
+		}
+	}()
+
+	src, err := iofs.New(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to open migrations: %w", err)
+	}
+
+	driver, err := migratepg.WithConnection(ctx, conn, &migratepg.Config{})
 	if err != nil {
 		return fmt.Errorf("failed to create database driver: %w", err)
 	}
 
-	migrator, err := migrate.NewWithDatabaseInstance("file://"+migrationsPath, "postgres", driver)
+	migrator, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
 	if err != nil {
 		return fmt.Errorf("failed to create migrator: %w", err)
 	}
 
+	status, err := readMigrationStatus(migrator, fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	if status.Version > status.Latest {
+		return fmt.Errorf("%w: database is at version %d, latest migration is %d", ErrUnknownSchemaVersion, status.Version, status.Latest)
+	}
+
+	if status.Dirty {
+		return fmt.Errorf("database is dirty at version %d, it must be fixed and forced to a version", status.Version)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			migrator.GracefulStop <- true
+		case <-done:
+		}
+	}()
+
 	if err := migrator.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		return fmt.Errorf("failed to run migrations: %v", err)
+		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	return nil
+	return ctx.Err()
+}
+
+func readMigrationStatus(migrator *migrate.Migrate, fsys fs.FS, dir string) (*MigrationStatus, error) {
+	var status MigrationStatus
+
+	var err error
+	status.Version, status.Dirty, err = migrator.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("failed to read database version: %w", err)
+	}
+
+	applied := err == nil
+
+	src, err := iofs.New(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %w", err)
+	}
+	defer src.Close()
+
+	identifiers, err := migrationIdentifiers(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := src.First()
+	for err == nil {
+		status.Latest = version
+
+		if identifier, ok := identifiers[version]; ok && (!applied || version > status.Version) {
+			status.Pending = append(status.Pending, fmt.Sprintf("%d_%s", version, identifier))
+		}
+
+		version, err = src.Next(version)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	return &status, nil
+}
+
+// migrationIdentifiers returns the identifiers of the up migrations in the directory,
+// by version, from their file names, so that the files don't need to be opened.
+func migrationIdentifiers(fsys fs.FS, dir string) (map[uint]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	identifiers := map[uint]string{}
+	for _, entry := range entries {
+		migration, err := source.Parse(entry.Name())
+		if err != nil || entry.IsDir() || migration.Direction != source.Up {
+			continue
+		}
+
+		identifiers[migration.Version] = migration.Identifier
+	}
+
+	return identifiers, nil
+}
+
+// CreateMigration creates empty up and down migration files in the given path,
+// versioned with the current timestamp, e.g. "20240102150405_add_users.up.sql".
+// It returns the paths of the created files.
+func CreateMigration(migrationsPath, name string) ([]string, error) {
+	name = strings.Trim(migrationNameReplacer.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, errors.New("migration name can't be empty")
+	}
+
+	if err := os.MkdirAll(migrationsPath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create migrations path: %w", err)
+	}
+
+	version := time.Now().UTC().Format(migrationTimeFormat)
+
+	var files []string
+	for _, direction := range []string{"up", "down"} {
+		file := filepath.Join(migrationsPath, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return files, fmt.Errorf("failed to create migration file: %w", err)
+		}
+
+		if err := f.Close(); err != nil {
+			return files, fmt.Errorf("failed to create migration file: %w", err)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+func newMigrator(db *sql.DB, fsys fs.FS, dir string) (*migrate.Migrate, error) {
+	src, err := iofs.New(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %w", err)
+	}
+
+	driver, err := migratepg.WithInstance(db, &migratepg.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database driver: %w", err)
+	}
+
+	migrator, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	return migrator, nil
 }
@@ -0,0 +1,47 @@
+package database_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tscolari/servicetools/database"
+)
+
+func Test_CreateMigration(t *testing.T) {
+	t.Run("creates up and down files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "migrations")
+
+		files, err := database.CreateMigration(path, "Add Users table")
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+
+		require.Regexp(t, regexp.MustCompile(`/\d{14}_add_users_table\.up\.sql$`), files[0])
+		require.Regexp(t, regexp.MustCompile(`/\d{14}_add_users_table\.down\.sql$`), files[1])
+
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			require.NoError(t, err)
+			require.Empty(t, content)
+		}
+	})
+
+	t.Run("empty name", func(t *testing.T) {
+		_, err := database.CreateMigration(t.TempDir(), " - ")
+		require.Error(t, err)
+	})
+}
+
+func Test_MigrateFS(t *testing.T) {
+	t.Run("missing migrations dir", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/1_init.up.sql": &fstest.MapFile{Data: []byte("SELECT 1")},
+		}
+
+		err := database.MigrateFS(nil, fsys, "other")
+		require.ErrorContains(t, err, "failed to open migrations")
+	})
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultConnectRetryInterval    = 500 * time.Millisecond
+	defaultConnectRetryMaxInterval = 10 * time.Second
+)
+
+// Open opens a connection pool to the database, configured with the given config,
+// and checks that the database is reachable.
+// If config.ConnectTimeout is set, failed attempts are retried with exponential
+// backoff and jitter until the timeout, or the context, expires.
+// Each attempt is logged using the given logger.
+// Queries are traced if config.TraceQueries is set, see WithTracing.
+func Open(ctx context.Context, logger *slog.Logger, config *Config) (*sql.DB, error) {
+	connector, err := newConnector("postgres", config.ToConnectStr())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if config.TraceQueries {
+		connector = WithTracing(connector)
+	}
+
+	db := sql.OpenDB(connector)
+
+	if config.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+
+	if config.ConnMaxLifeTime > 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifeTime)
+	}
+
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+
+	if err := ping(ctx, logger, db, config); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping the database: %w", err)
+	}
+
+	return db, nil
+}
+
+// newConnector returns a connector for the registered driver with the given name.
+func newConnector(driverName, dsn string) (driver.Connector, error) {
+	// sql.Open doesn't connect, it's only used to find the driver.
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := db.Driver()
+	_ = db.Close()
+
+	if driverCtx, ok := registered.(driver.DriverContext); ok {
+		return driverCtx.OpenConnector(dsn)
+	}
+
+	return dsnConnector{dsn: dsn, driver: registered}, nil
+}
+
+// ping checks the connection to the database, retrying until config.ConnectTimeout.
+func ping(ctx context.Context, logger *slog.Logger, db *sql.DB, config *Config) error {
+	if config.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ConnectTimeout)
+		defer cancel()
+	}
+
+	interval := config.ConnectRetryInterval
+	if interval <= 0 {
+		interval = defaultConnectRetryInterval
+	}
+
+	maxInterval := config.ConnectRetryMaxInterval
+	if maxInterval <= 0 {
+		maxInterval = defaultConnectRetryMaxInterval
+	}
+
+	logger = logger.With("hostname", config.Hostname, "port", config.Port, "db_name", config.DBName)
+
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			logger.Info("connected to the database", "attempt", attempt)
+			return nil
+		}
+
+		if config.ConnectTimeout <= 0 {
+			return err
+		}
+
+		// Equal jitter: wait between half and the whole interval.
+		wait := interval/2 + rand.N(interval/2+1)
+		logger.Warn("failed to connect to the database, retrying", "attempt", attempt, "retry_in", wait, "error", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up after %d attempts (%w): %w", attempt, ctx.Err(), err)
+		case <-timer.C:
+		}
+
+		interval = min(interval*2, maxInterval)
+	}
+}
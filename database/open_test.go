@@ -0,0 +1,62 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tscolari/servicetools/database"
+)
+
+func Test_Open(t *testing.T) {
+	// Nothing listens on port 1, so every attempt fails.
+	unreachable := database.Config{
+		Hostname: "127.0.0.1",
+		Port:     1,
+		Username: "postgres",
+		Password: "postgres",
+		DBName:   "postgres",
+	}
+
+	t.Run("without connect timeout", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		logger := slog.New(slog.NewTextHandler(logs, nil))
+
+		_, err := database.Open(context.Background(), logger, &unreachable)
+		require.ErrorContains(t, err, "failed to ping the database")
+		require.Empty(t, logs.String())
+	})
+
+	t.Run("retrying until the connect timeout", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		logger := slog.New(slog.NewTextHandler(logs, nil))
+
+		config := unreachable
+		config.ConnectTimeout = 300 * time.Millisecond
+		config.ConnectRetryInterval = 20 * time.Millisecond
+		config.ConnectRetryMaxInterval = 50 * time.Millisecond
+
+		start := time.Now()
+		_, err := database.Open(context.Background(), logger, &config)
+		require.ErrorContains(t, err, "gave up after")
+		require.WithinDuration(t, start.Add(config.ConnectTimeout), time.Now(), 200*time.Millisecond)
+
+		attempts := strings.Count(logs.String(), "failed to connect to the database, retrying")
+		require.Greater(t, attempts, 2)
+	})
+
+	t.Run("when the context is canceled", func(t *testing.T) {
+		config := unreachable
+		config.ConnectTimeout = time.Minute
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := database.Open(ctx, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), &config)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	"github.com/tscolari/servicetools/tracing"
+)
+
+// WithTracing wraps the connector so that the queries made with its connections are
+// traced (see the tracing package), e.g. with sql.OpenDB(database.WithTracing(connector)).
+// Spans are only created for queries whose context has a span, e.g. in gRPC and HTTP
+// handlers or worker tasks, and they end when the query returns, before its rows are read.
+// Connections opened by Open are traced if Config.TraceQueries is set.
+func WithTracing(connector driver.Connector) driver.Connector {
+	return &tracedConnector{Connector: connector}
+}
+
+// UnwrapConn returns the connection of the driver wrapped by WithTracing, e.g. to
+// be used with sql.Conn.Raw. Other connections are returned as they are.
+func UnwrapConn(conn any) any {
+	if traced, ok := conn.(*tracedConn); ok {
+		return traced.Conn
+	}
+
+	return conn
+}
+
+// dsnConnector is the driver.Connector of drivers that don't implement driver.DriverContext.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type tracedConnector struct {
+	driver.Connector
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{Conn: conn}, nil
+}
+
+// startQuerySpan starts a span named after the first keyword of the query,
+// e.g. "db SELECT", if the context has a span.
+func startQuerySpan(ctx context.Context, query string) (context.Context, *tracing.Span) {
+	if _, ok := tracing.SpanFromContext(ctx); !ok {
+		return ctx, nil
+	}
+
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+
+	ctx, span := tracing.Start(ctx, "db "+strings.ToUpper(operation), tracing.KindClient)
+	span.SetAttribute("db.statement", query)
+
+	return ctx, span
+}
+
+func endQuerySpan(span *tracing.Span, err error) {
+	if span == nil {
+		return
+	}
+
+	// ErrSkip means the driver falls back to another method, which is traced instead.
+	if err != driver.ErrSkip {
+		span.SetError(err)
+		span.End()
+	}
+}
+
+// tracedConn traces the queries of a connection, passing the optional interfaces
+// of database/sql/driver through to the wrapped one.
+type tracedConn struct {
+	driver.Conn
+}
+
+var (
+	_ driver.ExecerContext      = &tracedConn{}
+	_ driver.QueryerContext     = &tracedConn{}
+	_ driver.ConnPrepareContext = &tracedConn{}
+	_ driver.ConnBeginTx        = &tracedConn{}
+	_ driver.Pinger             = &tracedConn{}
+	_ driver.SessionResetter    = &tracedConn{}
+	_ driver.Validator          = &tracedConn{}
+	_ driver.NamedValueChecker  = &tracedConn{}
+)
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startQuerySpan(ctx, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	endQuerySpan(span, err)
+
+	return result, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startQuerySpan(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	endQuerySpan(span, err)
+
+	return rows, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedStmt{Stmt: stmt, query: query}, nil
+}
+
+// BeginTx fails, like database/sql does, if the options can't be honoured by the wrapped connection.
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, errIsolationLevel
+	}
+
+	if opts.ReadOnly {
+		return nil, errReadOnly
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	// ErrSkip makes database/sql use its default conversion.
+	return driver.ErrSkip
+}
+
+// tracedStmt traces the executions of a prepared statement.
+type tracedStmt struct {
+	driver.Stmt
+	query string
+}
+
+var (
+	_ driver.StmtExecContext  = &tracedStmt{}
+	_ driver.StmtQueryContext = &tracedStmt{}
+)
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, span := startQuerySpan(ctx, s.query)
+
+	var result driver.Result
+	var err error
+
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			result, err = s.Stmt.Exec(values)
+		}
+	}
+
+	endQuerySpan(span, err)
+	return result, err
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, span := startQuerySpan(ctx, s.query)
+
+	var rows driver.Rows
+	var err error
+
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+var (
+	errNamedArgs      = errors.New("the driver doesn't support named arguments")
+	errIsolationLevel = errors.New("the driver doesn't support non-default isolation levels")
+	errReadOnly       = errors.New("the driver doesn't support read-only transactions")
+)
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errNamedArgs
+		}
+
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
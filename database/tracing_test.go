@@ -0,0 +1,129 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tscolari/servicetools/database"
+	"github.com/tscolari/servicetools/tracing"
+)
+
+func Test_WithTracing(t *testing.T) {
+	exporter := tracing.NewMemoryExporter()
+	tracing.SetExporter(exporter)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+
+	db := sql.OpenDB(database.WithTracing(fakeConnector{}))
+	defer db.Close()
+
+	t.Run("queries in a span", func(t *testing.T) {
+		exporter.Reset()
+
+		ctx, span := tracing.Start(context.Background(), "request", tracing.KindServer)
+
+		_, err := db.ExecContext(ctx, "insert into users values ($1)", "name")
+		require.NoError(t, err)
+
+		_, err = db.ExecContext(ctx, "delete from users")
+		require.Error(t, err)
+
+		// The fake driver only queries with prepared statements.
+		rows, err := db.QueryContext(ctx, "select * from users")
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+
+		span.End()
+
+		spans := exporter.Spans()
+		require.Len(t, spans, 4)
+
+		require.Equal(t, "db INSERT", spans[0].Name)
+		require.Equal(t, tracing.KindClient, spans[0].Kind)
+		require.Equal(t, "insert into users values ($1)", spans[0].Attributes["db.statement"])
+		require.Equal(t, spans[3].SpanID, spans[0].ParentSpanID)
+
+		require.Equal(t, "db DELETE", spans[1].Name)
+		require.Equal(t, "delete failed", spans[1].Error)
+
+		require.Equal(t, "db SELECT", spans[2].Name)
+		require.Equal(t, spans[3].SpanID, spans[2].ParentSpanID)
+	})
+
+	t.Run("queries without a span", func(t *testing.T) {
+		exporter.Reset()
+
+		_, err := db.ExecContext(context.Background(), "insert into users values ($1)", "name")
+		require.NoError(t, err)
+		require.Empty(t, exporter.Spans())
+	})
+
+	t.Run("transaction options the driver doesn't support", func(t *testing.T) {
+		tx, err := db.BeginTx(context.Background(), nil)
+		require.NoError(t, err)
+		require.NoError(t, tx.Rollback())
+
+		_, err = db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+		require.ErrorContains(t, err, "read-only transactions")
+
+		_, err = db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+		require.ErrorContains(t, err, "isolation levels")
+	})
+
+	t.Run("raw driver connections", func(t *testing.T) {
+		conn, err := db.Conn(context.Background())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, conn.Raw(func(driverConn any) error {
+			require.NotEqual(t, fakeConn{}, driverConn)
+			require.Equal(t, fakeConn{}, database.UnwrapConn(driverConn))
+			return nil
+		}))
+
+		require.Equal(t, fakeConn{}, database.UnwrapConn(fakeConn{}))
+	})
+}
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+// fakeConn executes statements directly, but only queries with prepared statements.
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if query == "delete from users" {
+		return nil, errors.New("delete failed")
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                               { return nil }
+func (fakeStmt) NumInput() int                              { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error)  { return fakeRows{}, nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return nil }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
@@ -1,13 +1,50 @@
 package logging
 
 import (
+	"io"
 	"log/slog"
 	"os"
 )
 
+// Formats supported by New.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Options defines how a logger created with New behaves.
+type Options struct {
+	// Level is the minimum level that gets logged.
+	// Using a *slog.LevelVar allows it to be changed at runtime (see LevelHandler).
+	// Defaults to slog.LevelInfo.
+	Level slog.Leveler
+
+	// Format is either FormatJSON or FormatText.
+	// Defaults to FormatJSON.
+	Format string
+
+	// Output is where the logs are written to.
+	// Defaults to os.Stdout.
+	Output io.Writer
+}
+
+// New returns a logger configured with the given options.
+func New(opts Options) *slog.Logger {
+	output := opts.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+
+	if opts.Format == FormatText {
+		return slog.New(slog.NewTextHandler(output, handlerOpts))
+	}
+
+	return slog.New(slog.NewJSONHandler(output, handlerOpts))
+}
+
 // Default returns a logger at default configuration.
 var Default = func() *slog.Logger {
-	return slog.New(
-		slog.NewJSONHandler(os.Stdout, nil),
-	)
+	return New(Options{})
 }
@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// LevelHandler returns an HTTP handler that exposes the given level:
+// GET responds with the current level, and PUT or POST change it to the
+// one given in the `level` parameter (e.g. `?level=debug`).
+func LevelHandler(level *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var newLevel slog.Level
+			if err := newLevel.UnmarshalText([]byte(r.FormValue("level"))); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			level.Set(newLevel)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"level": level.Level().String()})
+	})
+}
+
+// ShiftLevel moves the given level by the given number of steps, where a step is
+// the distance between two of the standard levels (e.g. from Info to Debug is -1).
+// The result is kept between slog.LevelDebug and slog.LevelError.
+func ShiftLevel(level *slog.LevelVar, steps int) slog.Level {
+	newLevel := level.Level() + slog.Level(steps*4)
+	newLevel = min(max(newLevel, slog.LevelDebug), slog.LevelError)
+
+	level.Set(newLevel)
+	return newLevel
+}
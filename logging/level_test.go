@@ -0,0 +1,50 @@
+package logging_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tscolari/servicetools/logging"
+)
+
+func Test_LevelHandler(t *testing.T) {
+	level := new(slog.LevelVar)
+	handler := logging.LevelHandler(level)
+
+	t.Run("getting the level", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.JSONEq(t, `{"level": "INFO"}`, recorder.Body.String())
+	})
+
+	t.Run("changing the level", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/?level=debug", nil))
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.JSONEq(t, `{"level": "DEBUG"}`, recorder.Body.String())
+		require.Equal(t, slog.LevelDebug, level.Level())
+	})
+
+	t.Run("invalid level", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/?level=loud", nil))
+
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+		require.Equal(t, slog.LevelDebug, level.Level())
+	})
+}
+
+func Test_ShiftLevel(t *testing.T) {
+	level := new(slog.LevelVar)
+
+	require.Equal(t, slog.LevelDebug, logging.ShiftLevel(level, -1))
+	require.Equal(t, slog.LevelDebug, logging.ShiftLevel(level, -1))
+	require.Equal(t, slog.LevelWarn, logging.ShiftLevel(level, 2))
+	require.Equal(t, slog.LevelError, logging.ShiftLevel(level, 5))
+}
@@ -0,0 +1,106 @@
+// Package requestid propagates request IDs, in the `x-request-id` header, across
+// services: servers take the ID from incoming requests (or generate one), store it in
+// the context and add it to the logger, and clients forward it with outgoing requests.
+// The gRPC interceptors are in the server/grpc package.
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tscolari/servicetools/logging"
+	"github.com/tscolari/servicetools/nanoid"
+)
+
+const (
+	// Header is the HTTP header, and gRPC metadata key, that carries the request ID.
+	Header = "x-request-id"
+
+	// LoggerField is the field of the logger annotated with the request ID.
+	LoggerField = "request_id"
+
+	// maxLength is the maximum length of request IDs accepted from clients.
+	maxLength = 128
+
+	// length is the length of generated request IDs.
+	length = 21
+)
+
+type contextKey struct{}
+
+// New returns a new request ID.
+func New() string {
+	return nanoid.New(nanoid.AlphabetDefault, length)
+}
+
+// OrNew returns the given request ID, as received from a client, if it's valid:
+// not empty, up to 128 characters and only printable ASCII characters.
+// Otherwise, it returns a new request ID.
+func OrNew(id string) string {
+	if id == "" || len(id) > maxLength {
+		return New()
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < ' ' || id[i] > '~' {
+			return New()
+		}
+	}
+
+	return id
+}
+
+// FromContext returns the request ID in the context, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok
+}
+
+// ToContext returns a context with the given request ID, and with its logger
+// (see logging.FromContext) annotated with it.
+func ToContext(ctx context.Context, id string) context.Context {
+	logger := logging.FromContext(ctx).With(LoggerField, id)
+	ctx = logging.ToContext(ctx, logger)
+
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// Handler takes the request ID from the requests, or generates one, and adds it to
+// their context (see ToContext) before calling next.
+// The request ID is also set in the response headers.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := OrNew(r.Header.Get(Header))
+		w.Header().Set(Header, id)
+
+		next.ServeHTTP(w, r.WithContext(ToContext(r.Context(), id)))
+	})
+}
+
+// Transport returns an http.RoundTripper that forwards the request ID in the
+// context of the requests, if any, unless they already have one.
+// If base is nil, http.DefaultTransport is used.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		id, ok := FromContext(r.Context())
+		if !ok || r.Header.Get(Header) != "" {
+			return base.RoundTrip(r)
+		}
+
+		// RoundTrippers must not modify the given request.
+		r = r.Clone(r.Context())
+		r.Header.Set(Header, id)
+
+		return base.RoundTrip(r)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
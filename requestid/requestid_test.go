@@ -0,0 +1,101 @@
+package requestid_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tscolari/servicetools/logging"
+	"github.com/tscolari/servicetools/requestid"
+)
+
+func Test_OrNew(t *testing.T) {
+	require.Equal(t, "abc-123", requestid.OrNew("abc-123"))
+
+	for _, invalid := range []string{"", strings.Repeat("a", 129), "line\nbreak", "ünicode"} {
+		id := requestid.OrNew(invalid)
+		require.NotEqual(t, invalid, id)
+		require.Len(t, id, 21)
+	}
+}
+
+func Test_ToContext(t *testing.T) {
+	out := &bytes.Buffer{}
+	ctx := logging.ToContext(context.Background(), slog.New(slog.NewTextHandler(out, nil)))
+
+	_, ok := requestid.FromContext(ctx)
+	require.False(t, ok)
+
+	ctx = requestid.ToContext(ctx, "abc")
+	id, ok := requestid.FromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, "abc", id)
+
+	logging.FromContext(ctx).Info("hello")
+	require.Contains(t, out.String(), "request_id=abc")
+}
+
+func Test_Handler(t *testing.T) {
+	var id string
+	handler := requestid.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = requestid.FromContext(r.Context())
+	}))
+
+	t.Run("using the given request ID", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Request-Id", "abc")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		require.Equal(t, "abc", id)
+		require.Equal(t, "abc", w.Header().Get(requestid.Header))
+	})
+
+	t.Run("generating a request ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		require.Len(t, id, 21)
+		require.Equal(t, id, w.Header().Get(requestid.Header))
+	})
+}
+
+func Test_Transport(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(requestid.Header)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: requestid.Transport(nil)}
+
+	get := func(ctx context.Context, header string) {
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		if header != "" {
+			r.Header.Set(requestid.Header, header)
+		}
+
+		resp, err := client.Do(r)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	ctx := requestid.ToContext(context.Background(), "abc")
+
+	get(ctx, "")
+	require.Equal(t, "abc", received)
+
+	get(ctx, "given")
+	require.Equal(t, "given", received)
+
+	get(context.Background(), "")
+	require.Empty(t, received)
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Component defines the lifecycle shared by all server capabilities
+// (WithGRPC, WithHTTP, WithWorker, WithMetrics, WithDB and WithRDB).
+// Custom components must implement it to be managed by the cmd package.
+type Component interface {
+	// Name returns a short name that identifies the component, e.g. "grpc".
+	Name() string
+
+	// Start starts the component and blocks until it is stopped.
+	Start(ctx context.Context, logger *slog.Logger) error
+
+	// Stop stops the component, causing Start to return.
+	Stop(ctx context.Context, logger *slog.Logger) error
+
+	// Ready returns a channel that is closed once the component has started.
+	Ready() <-chan struct{}
+
+	// State returns the current lifecycle state of the component.
+	State() State
+}
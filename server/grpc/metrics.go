@@ -0,0 +1,226 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Types of RPCs, used in the `grpc_type` label.
+const (
+	typeUnary        = "unary"
+	typeClientStream = "client_stream"
+	typeServerStream = "server_stream"
+	typeBidiStream   = "bidi_stream"
+)
+
+// MetricsOptions configures the metrics recorded by the interceptors of Metrics.
+type MetricsOptions struct {
+	// Buckets are the buckets of the latency histogram, in seconds.
+	// It defaults to prometheus.DefBuckets.
+	Buckets []float64
+
+	// ExcludedMethods are not recorded, e.g. health checks. Each entry is either a
+	// full method name ("/grpc.health.v1.Health/Check") or a service name ending
+	// with a slash ("/grpc.health.v1.Health/"), to exclude all of its methods.
+	ExcludedMethods []string
+}
+
+// NewMetrics returns the RED (rate, errors and duration) metrics of a gRPC server.
+// They must be registered (e.g. with WithMetrics.Registerer) to be exposed, and are
+// recorded by the UnaryInterceptor and StreamInterceptor.
+func NewMetrics(opts MetricsOptions) *Metrics {
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	labels := []string{"grpc_type", "grpc_service", "grpc_method"}
+	sizeBuckets := prometheus.ExponentialBuckets(64, 4, 8)
+
+	return &Metrics{
+		excluded: opts.ExcludedMethods,
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Total number of RPCs completed on the server, regardless of success or failure.",
+		}, append(labels, "grpc_code")),
+		handling: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Latency of the RPCs handled by the server, in seconds.",
+			Buckets: buckets,
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_server_in_flight",
+			Help: "Number of RPCs currently being handled by the server.",
+		}, labels),
+		received: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_msg_received_bytes",
+			Help:    "Size of the messages received by the server, in bytes.",
+			Buckets: sizeBuckets,
+		}, labels),
+		sent: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_msg_sent_bytes",
+			Help:    "Size of the messages sent by the server, in bytes.",
+			Buckets: sizeBuckets,
+		}, labels),
+	}
+}
+
+// Metrics holds the metrics of a gRPC server, labeled by the RPC type, service,
+// method and, for the number of RPCs handled, the status code.
+// It's a prometheus.Collector.
+type Metrics struct {
+	excluded []string
+
+	handled  *prometheus.CounterVec
+	handling *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	received *prometheus.HistogramVec
+	sent     *prometheus.HistogramVec
+}
+
+var _ prometheus.Collector = &Metrics{}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.handled.Describe(ch)
+	m.handling.Describe(ch)
+	m.inFlight.Describe(ch)
+	m.received.Describe(ch)
+	m.sent.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.handled.Collect(ch)
+	m.handling.Collect(ch)
+	m.inFlight.Collect(ch)
+	m.received.Collect(ch)
+	m.sent.Collect(ch)
+}
+
+// UnaryInterceptor records the metrics of unary RPCs.
+func (m *Metrics) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	if m.isExcluded(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	labels := rpcLabels(typeUnary, info.FullMethod)
+	done := m.begin(labels)
+
+	observeSize(m.received, labels, req)
+	resp, err = handler(ctx, req)
+	if err == nil {
+		observeSize(m.sent, labels, resp)
+	}
+
+	done(err)
+	return resp, err
+}
+
+// StreamInterceptor records the metrics of streaming RPCs.
+// The latency of a stream is the time until the handler returns.
+func (m *Metrics) StreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if m.isExcluded(info.FullMethod) {
+		return handler(srv, stream)
+	}
+
+	labels := rpcLabels(streamType(info), info.FullMethod)
+	done := m.begin(labels)
+
+	err := handler(srv, &measuringServerStream{ServerStream: stream, metrics: m, labels: labels})
+
+	done(err)
+	return err
+}
+
+// begin records an RPC as in-flight, and returns the function that records its result.
+func (m *Metrics) begin(labels prometheus.Labels) func(error) {
+	start := time.Now()
+	inFlight := m.inFlight.With(labels)
+	inFlight.Inc()
+
+	return func(err error) {
+		inFlight.Dec()
+		m.handling.With(labels).Observe(time.Since(start).Seconds())
+
+		handledLabels := prometheus.Labels{"grpc_code": status.Code(err).String()}
+		for name, value := range labels {
+			handledLabels[name] = value
+		}
+
+		m.handled.With(handledLabels).Inc()
+	}
+}
+
+func (m *Metrics) isExcluded(fullMethod string) bool {
+	for _, excluded := range m.excluded {
+		if excluded == fullMethod || (strings.HasSuffix(excluded, "/") && strings.HasPrefix(fullMethod, excluded)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rpcLabels returns the labels of an RPC by its full method name, e.g. "/pkg.Service/Method".
+func rpcLabels(rpcType, fullMethod string) prometheus.Labels {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		service, method = "unknown", fullMethod
+	}
+
+	return prometheus.Labels{
+		"grpc_type":    rpcType,
+		"grpc_service": service,
+		"grpc_method":  method,
+	}
+}
+
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return typeBidiStream
+	case info.IsClientStream:
+		return typeClientStream
+	default:
+		return typeServerStream
+	}
+}
+
+// observeSize records the size of the message, if it's a protobuf message.
+func observeSize(histogram *prometheus.HistogramVec, labels prometheus.Labels, message any) {
+	if msg, ok := message.(proto.Message); ok {
+		histogram.With(labels).Observe(float64(proto.Size(msg)))
+	}
+}
+
+// measuringServerStream records the size of the messages received and sent successfully.
+type measuringServerStream struct {
+	grpc.ServerStream
+	metrics *Metrics
+	labels  prometheus.Labels
+}
+
+func (s *measuringServerStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		observeSize(s.metrics.received, s.labels, m)
+	}
+
+	return err
+}
+
+func (s *measuringServerStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		observeSize(s.metrics.sent, s.labels, m)
+	}
+
+	return err
+}
@@ -0,0 +1,150 @@
+package grpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	grpcsrv "github.com/tscolari/servicetools/server/grpc"
+)
+
+func Test_Metrics(t *testing.T) {
+	newMetrics := func(t *testing.T, opts grpcsrv.MetricsOptions) (*grpcsrv.Metrics, *prometheus.Registry) {
+		metrics := grpcsrv.NewMetrics(opts)
+		registry := prometheus.NewRegistry()
+		require.NoError(t, registry.Register(metrics))
+
+		return metrics, registry
+	}
+
+	t.Run("unary RPCs", func(t *testing.T) {
+		metrics, registry := newMetrics(t, grpcsrv.MetricsOptions{Buckets: []float64{0.5, 1}})
+		info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Echo"}
+
+		var inFlight float64
+		handler := func(ctx context.Context, req any) (any, error) {
+			inFlight = gatherValue(t, registry, "grpc_server_in_flight")
+			return req, nil
+		}
+
+		_, err := metrics.UnaryInterceptor(context.Background(), wrapperspb.String("hello"), info, handler)
+		require.NoError(t, err)
+
+		_, err = metrics.UnaryInterceptor(context.Background(), wrapperspb.String("hello"), info,
+			func(ctx context.Context, req any) (any, error) {
+				return nil, status.Error(codes.NotFound, "not found")
+			},
+		)
+		require.Equal(t, codes.NotFound, status.Code(err))
+
+		require.Equal(t, float64(1), inFlight)
+		require.Equal(t, float64(0), gatherValue(t, registry, "grpc_server_in_flight"))
+
+		handled := gather(t, registry, "grpc_server_handled_total")
+		require.Len(t, handled, 2)
+		for i, code := range []string{"NotFound", "OK"} {
+			require.Equal(t, map[string]string{
+				"grpc_type":    "unary",
+				"grpc_service": "test.Service",
+				"grpc_method":  "Echo",
+				"grpc_code":    code,
+			}, labels(handled[i]))
+			require.Equal(t, float64(1), handled[i].GetCounter().GetValue())
+		}
+
+		latency := gather(t, registry, "grpc_server_handling_seconds")[0].GetHistogram()
+		require.Equal(t, uint64(2), latency.GetSampleCount())
+		require.Len(t, latency.GetBucket(), 2)
+		require.Equal(t, 0.5, latency.GetBucket()[0].GetUpperBound())
+
+		received := gather(t, registry, "grpc_server_msg_received_bytes")[0].GetHistogram()
+		require.Equal(t, uint64(2), received.GetSampleCount())
+		require.Equal(t, float64(2*proto.Size(wrapperspb.String("hello"))), received.GetSampleSum())
+
+		// Responses of failed RPCs are not measured.
+		require.Equal(t, uint64(1), gather(t, registry, "grpc_server_msg_sent_bytes")[0].GetHistogram().GetSampleCount())
+	})
+
+	t.Run("streaming RPCs", func(t *testing.T) {
+		metrics, registry := newMetrics(t, grpcsrv.MetricsOptions{})
+		info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Chat", IsClientStream: true, IsServerStream: true}
+
+		err := metrics.StreamInterceptor(nil, &fakeServerStream{ctx: context.Background()}, info,
+			func(srv any, stream grpc.ServerStream) error {
+				require.NoError(t, stream.SendMsg(wrapperspb.String("one")))
+				require.NoError(t, stream.SendMsg(wrapperspb.String("two")))
+				return status.Error(codes.Aborted, "done")
+			},
+		)
+		require.Equal(t, codes.Aborted, status.Code(err))
+
+		handled := gather(t, registry, "grpc_server_handled_total")
+		require.Len(t, handled, 1)
+		require.Equal(t, map[string]string{
+			"grpc_type":    "bidi_stream",
+			"grpc_service": "test.Service",
+			"grpc_method":  "Chat",
+			"grpc_code":    "Aborted",
+		}, labels(handled[0]))
+
+		require.Equal(t, uint64(1), gather(t, registry, "grpc_server_handling_seconds")[0].GetHistogram().GetSampleCount())
+		require.Equal(t, uint64(2), gather(t, registry, "grpc_server_msg_sent_bytes")[0].GetHistogram().GetSampleCount())
+	})
+
+	t.Run("excluded methods", func(t *testing.T) {
+		metrics, registry := newMetrics(t, grpcsrv.MetricsOptions{
+			ExcludedMethods: []string{"/grpc.health.v1.Health/", "/test.Service/Ping"},
+		})
+
+		handler := func(ctx context.Context, req any) (any, error) { return req, nil }
+
+		for _, method := range []string{"/grpc.health.v1.Health/Check", "/test.Service/Ping", "/test.Service/Echo"} {
+			_, err := metrics.UnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			require.NoError(t, err)
+		}
+
+		handled := gather(t, registry, "grpc_server_handled_total")
+		require.Len(t, handled, 1)
+		require.Equal(t, "Echo", labels(handled[0])["grpc_method"])
+	})
+}
+
+// gather returns the metrics with the given name.
+func gather(t *testing.T, registry *prometheus.Registry, name string) []*dto.Metric {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()
+		}
+	}
+
+	require.Failf(t, "metric not found", "no %q metric", name)
+	return nil
+}
+
+// gatherValue returns the value of the gauge with the given name, which must have a single metric.
+func gatherValue(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	metrics := gather(t, registry, name)
+	require.Len(t, metrics, 1)
+
+	return metrics[0].GetGauge().GetValue()
+}
+
+func labels(metric *dto.Metric) map[string]string {
+	labels := map[string]string{}
+	for _, label := range metric.GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+
+	return labels
+}
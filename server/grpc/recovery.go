@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tscolari/servicetools/logging"
+)
+
+// NewRecovery returns the interceptors that recover from panics in the handlers.
+// The recovered panics are counted, but the counter must be registered
+// (e.g. with WithMetrics.Registerer) to be exposed.
+func NewRecovery() *Recovery {
+	return &Recovery{
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_panics_recovered_total",
+			Help: "Total number of panics recovered from gRPC handlers.",
+		}, []string{"grpc_type", "grpc_service", "grpc_method"}),
+	}
+}
+
+// Recovery recovers from panics in the handlers of a gRPC server, counting them
+// by the RPC type, service and method.
+// It's a prometheus.Collector.
+type Recovery struct {
+	panics *prometheus.CounterVec
+}
+
+var _ prometheus.Collector = &Recovery{}
+
+// Describe implements prometheus.Collector.
+func (r *Recovery) Describe(ch chan<- *prometheus.Desc) {
+	r.panics.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (r *Recovery) Collect(ch chan<- prometheus.Metric) {
+	r.panics.Collect(ch)
+}
+
+// UnaryInterceptor recovers from panics in the handler, returning a codes.Internal
+// error, without any details, to the client.
+// The panic and its stack trace are logged with the logger from the context, so it
+// should run after LoggerAnnotationInterceptor.
+func (r *Recovery) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = r.recovered(ctx, typeUnary, info.FullMethod, p)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// StreamInterceptor recovers from panics in the handler of a stream.
+// It's the streaming equivalent of UnaryInterceptor.
+func (r *Recovery) StreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = r.recovered(stream.Context(), streamType(info), info.FullMethod, p)
+		}
+	}()
+
+	return handler(srv, stream)
+}
+
+// recovered records and logs a recovered panic, and returns the error for the client.
+func (r *Recovery) recovered(ctx context.Context, rpcType, fullMethod string, p any) error {
+	r.panics.With(rpcLabels(rpcType, fullMethod)).Inc()
+
+	logging.FromContext(ctx).Error("panic recovered",
+		"panic", p,
+		"stack", string(debug.Stack()),
+	)
+
+	return status.Error(codes.Internal, "internal error")
+}
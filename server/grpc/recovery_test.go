@@ -0,0 +1,97 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tscolari/servicetools/logging"
+	grpcsrv "github.com/tscolari/servicetools/server/grpc"
+)
+
+func Test_RecoveryInterceptor(t *testing.T) {
+	recovery := grpcsrv.NewRecovery()
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(recovery))
+
+	panics := func(t *testing.T, method string) float64 {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+
+		for _, family := range families {
+			if family.GetName() != "grpc_server_panics_recovered_total" {
+				continue
+			}
+
+			for _, metric := range family.GetMetric() {
+				if labels(metric)["grpc_method"] == method {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+
+		return 0
+	}
+
+	newLogger := func() (*slog.Logger, *bytes.Buffer) {
+		out := &bytes.Buffer{}
+		return slog.New(slog.NewJSONHandler(out, nil)), out
+	}
+
+	t.Run("unary RPCs", func(t *testing.T) {
+		logger, out := newLogger()
+		ctx := logging.ToContext(context.Background(), logger.With("grpc_method", "/test.Service/Boom"))
+		info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Boom"}
+
+		resp, err := recovery.UnaryInterceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			panic("secret details")
+		})
+		require.Nil(t, resp)
+		require.Equal(t, codes.Internal, status.Code(err))
+		require.NotContains(t, err.Error(), "secret details")
+
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+		require.Equal(t, "panic recovered", line["msg"])
+		require.Equal(t, "secret details", line["panic"])
+		require.Equal(t, "/test.Service/Boom", line["grpc_method"])
+		require.Contains(t, line["stack"], "recovery_test.go")
+
+		require.Equal(t, float64(1), panics(t, "Boom"))
+	})
+
+	t.Run("streaming RPCs", func(t *testing.T) {
+		logger, out := newLogger()
+		stream := &fakeServerStream{ctx: logging.ToContext(context.Background(), logger)}
+		info := &grpc.StreamServerInfo{FullMethod: "/test.Service/BoomStream", IsServerStream: true}
+
+		err := recovery.StreamInterceptor(nil, stream, info, func(srv any, stream grpc.ServerStream) error {
+			var m map[string]string
+			m["nil map"] = "panics"
+			return nil
+		})
+		require.Equal(t, codes.Internal, status.Code(err))
+		require.Contains(t, out.String(), "panic recovered")
+
+		require.Equal(t, float64(1), panics(t, "BoomStream"))
+	})
+
+	t.Run("without panics", func(t *testing.T) {
+		info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Fine"}
+
+		resp, err := recovery.UnaryInterceptor(context.Background(), "req", info, func(ctx context.Context, req any) (any, error) {
+			return req, status.Error(codes.NotFound, "not found")
+		})
+		require.Equal(t, "req", resp)
+		require.Equal(t, codes.NotFound, status.Code(err))
+		require.Equal(t, float64(0), panics(t, "Fine"))
+	})
+}
@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/tscolari/servicetools/requestid"
+)
+
+// RequestIDInterceptor takes the request ID from the `x-request-id` metadata, or
+// generates one, and adds it to the context and its logger (see requestid.ToContext).
+// The request ID is also sent back in the response headers.
+// It should run after LoggerInterceptor, so that the logger is annotated.
+func RequestIDInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	id := incomingRequestID(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestid.Header, id))
+
+	return handler(requestid.ToContext(ctx, id), req)
+}
+
+// StreamRequestIDInterceptor adds the request ID to the context of the stream.
+// It's the streaming equivalent of RequestIDInterceptor.
+func StreamRequestIDInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	id := incomingRequestID(stream.Context())
+	_ = stream.SetHeader(metadata.Pairs(requestid.Header, id))
+
+	return handler(srv, WrapServerStream(requestid.ToContext(stream.Context(), id), stream))
+}
+
+func incomingRequestID(ctx context.Context) string {
+	var id string
+	if values := metadata.ValueFromIncomingContext(ctx, requestid.Header); len(values) > 0 {
+		id = values[0]
+	}
+
+	return requestid.OrNew(id)
+}
+
+// RequestIDClientInterceptor forwards the request ID in the context, if any, in
+// the metadata of outgoing RPCs, e.g. with grpc.WithChainUnaryInterceptor.
+func RequestIDClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+}
+
+// StreamRequestIDClientInterceptor forwards the request ID in the context, if any,
+// in the metadata of outgoing streams, e.g. with grpc.WithChainStreamInterceptor.
+func StreamRequestIDClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+}
+
+// outgoingRequestID adds the request ID to the outgoing metadata, unless it's already there.
+func outgoingRequestID(ctx context.Context) context.Context {
+	id, ok := requestid.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	if md, _ := metadata.FromOutgoingContext(ctx); len(md.Get(requestid.Header)) > 0 {
+		return ctx
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, requestid.Header, id)
+}
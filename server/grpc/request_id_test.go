@@ -0,0 +1,63 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/tscolari/servicetools/logging"
+	"github.com/tscolari/servicetools/requestid"
+	grpcsrv "github.com/tscolari/servicetools/server/grpc"
+)
+
+func Test_StreamRequestIDInterceptor(t *testing.T) {
+	out := &bytes.Buffer{}
+	ctx := logging.ToContext(context.Background(), slog.New(slog.NewTextHandler(out, nil)))
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-request-id", "abc"))
+
+	stream := &fakeServerStream{ctx: ctx}
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Chat"}
+
+	var received string
+	err := grpcsrv.StreamRequestIDInterceptor(nil, stream, info, func(srv any, stream grpc.ServerStream) error {
+		received, _ = requestid.FromContext(stream.Context())
+		logging.FromContext(stream.Context()).Info("from handler")
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "abc", received)
+	require.Equal(t, []string{"abc"}, stream.header.Get(requestid.Header))
+	require.Contains(t, out.String(), "request_id=abc")
+}
+
+func Test_RequestIDClientInterceptor(t *testing.T) {
+	var outgoing metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+
+	t.Run("forwarding the request ID", func(t *testing.T) {
+		ctx := requestid.ToContext(context.Background(), "abc")
+		require.NoError(t, grpcsrv.RequestIDClientInterceptor(ctx, "/test.Service/Echo", nil, nil, nil, invoker))
+		require.Equal(t, []string{"abc"}, outgoing.Get(requestid.Header))
+	})
+
+	t.Run("keeping a given request ID", func(t *testing.T) {
+		ctx := requestid.ToContext(context.Background(), "abc")
+		ctx = metadata.AppendToOutgoingContext(ctx, requestid.Header, "given")
+		require.NoError(t, grpcsrv.RequestIDClientInterceptor(ctx, "/test.Service/Echo", nil, nil, nil, invoker))
+		require.Equal(t, []string{"given"}, outgoing.Get(requestid.Header))
+	})
+
+	t.Run("without a request ID", func(t *testing.T) {
+		require.NoError(t, grpcsrv.RequestIDClientInterceptor(context.Background(), "/test.Service/Echo", nil, nil, nil, invoker))
+		require.Empty(t, outgoing.Get(requestid.Header))
+	})
+}
@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/tscolari/servicetools/logging"
+)
+
+const (
+	loggerFieldMessagesReceived = "messages_received"
+	loggerFieldMessagesSent     = "messages_sent"
+)
+
+// StreamLoggerInterceptor adds a logger to the context of the stream.
+// It's the streaming equivalent of LoggerInterceptor.
+func StreamLoggerInterceptor(logger *slog.Logger) func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := logging.ToContext(stream.Context(), logger)
+		return handler(srv, WrapServerStream(ctx, stream))
+	}
+}
+
+// StreamLoggerAnnotationInterceptor adds annotations to the logger of the stream.
+// It also logs debugging information for every stream, including how many messages
+// were received and sent.
+// It's the streaming equivalent of LoggerAnnotationInterceptor.
+func StreamLoggerAnnotationInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	logger := logging.FromContext(stream.Context())
+	logger = logger.With(loggerFieldMethod, info.FullMethod)
+
+	now := time.Now()
+	logger.Debug("stream started",
+		"client_stream", info.IsClientStream,
+		"server_stream", info.IsServerStream,
+	)
+
+	counted := &countingServerStream{
+		ServerStream: WrapServerStream(logging.ToContext(stream.Context(), logger), stream),
+	}
+
+	err := handler(srv, counted)
+
+	errCode := status.Code(err).String()
+	logger.Debug(
+		"stream finished",
+		"error", err,
+		loggerFieldStatusCode, errCode,
+		loggerFieldMessagesReceived, counted.received.Load(),
+		loggerFieldMessagesSent, counted.sent.Load(),
+		"duration", time.Since(now).String(),
+	)
+
+	return err
+}
+
+// WrapServerStream returns a grpc.ServerStream that has the given context,
+// so that stream interceptors can pass values to the handlers.
+func WrapServerStream(ctx context.Context, stream grpc.ServerStream) grpc.ServerStream {
+	return &wrappedServerStream{ServerStream: stream, ctx: ctx}
+}
+
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// countingServerStream counts the messages received and sent successfully.
+type countingServerStream struct {
+	grpc.ServerStream
+	received atomic.Int64
+	sent     atomic.Int64
+}
+
+func (s *countingServerStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received.Add(1)
+	}
+
+	return err
+}
+
+func (s *countingServerStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent.Add(1)
+	}
+
+	return err
+}
@@ -0,0 +1,105 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/tscolari/servicetools/logging"
+	grpcsrv "github.com/tscolari/servicetools/server/grpc"
+)
+
+func Test_StreamInterceptors(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	stream := &fakeServerStream{ctx: context.Background(), messages: 2}
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Chat", IsClientStream: true, IsServerStream: true}
+
+	handler := func(srv any, stream grpc.ServerStream) error {
+		logging.FromContext(stream.Context()).Info("from handler")
+
+		for {
+			var msg string
+			if err := stream.RecvMsg(&msg); err != nil {
+				break
+			}
+			require.NoError(t, stream.SendMsg(msg))
+		}
+
+		return status.Error(codes.Aborted, "done")
+	}
+
+	// Chained as in WithGRPC.
+	err := grpcsrv.StreamLoggerInterceptor(logger)(nil, stream, info, func(srv any, stream grpc.ServerStream) error {
+		return grpcsrv.StreamLoggerAnnotationInterceptor(srv, stream, info, handler)
+	})
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	var lines []map[string]any
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		var line map[string]any
+		require.NoError(t, decoder.Decode(&line))
+		lines = append(lines, line)
+	}
+
+	require.Len(t, lines, 3)
+
+	require.Equal(t, "stream started", lines[0]["msg"])
+	require.Equal(t, "/test.Service/Chat", lines[0]["grpc_method"])
+
+	require.Equal(t, "from handler", lines[1]["msg"])
+	require.Equal(t, "/test.Service/Chat", lines[1]["grpc_method"])
+
+	require.Equal(t, "stream finished", lines[2]["msg"])
+	require.Equal(t, "Aborted", lines[2]["status_code"])
+	require.EqualValues(t, 2, lines[2]["messages_received"])
+	require.EqualValues(t, 2, lines[2]["messages_sent"])
+	require.Contains(t, lines[2], "duration")
+}
+
+// fakeServerStream receives the given number of messages, and accepts any sent ones.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	messages int
+	header   metadata.MD
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) RecvMsg(m any) error {
+	if s.messages == 0 {
+		return io.EOF
+	}
+
+	s.messages--
+	*(m.(*string)) = "message"
+	return nil
+}
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *fakeServerStream) SendMsg(m any) error {
+	if m == nil {
+		return errors.New("nil message")
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/tscolari/servicetools/tracing"
+)
+
+// TracingInterceptor creates a server span around every RPC, as a child of the trace
+// context in the `traceparent` and `tracestate` metadata, if any.
+// The handler gets the span, and a logger annotated with it, from the context,
+// so it should run after LoggerInterceptor.
+func TracingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	ctx, span := startServerSpan(ctx, info.FullMethod)
+	defer span.End()
+
+	resp, err = handler(ctx, req)
+	endRPCSpan(span, err)
+
+	return resp, err
+}
+
+// StreamTracingInterceptor creates a server span around every stream.
+// It's the streaming equivalent of TracingInterceptor.
+func StreamTracingInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := startServerSpan(stream.Context(), info.FullMethod)
+	defer span.End()
+
+	err := handler(srv, WrapServerStream(ctx, stream))
+	endRPCSpan(span, err)
+
+	return err
+}
+
+func startServerSpan(ctx context.Context, fullMethod string) (context.Context, *tracing.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var traceparent string
+	if values := md.Get(tracing.TraceparentHeader); len(values) > 0 {
+		traceparent = values[0]
+	}
+
+	ctx = tracing.Extract(ctx, traceparent, strings.Join(md.Get(tracing.TracestateHeader), ","))
+	return startRPCSpan(ctx, fullMethod, tracing.KindServer)
+}
+
+// startRPCSpan starts a span named after the method, without the leading slash,
+// e.g. "pkg.Service/Method".
+func startRPCSpan(ctx context.Context, fullMethod string, kind tracing.Kind) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, strings.TrimPrefix(fullMethod, "/"), kind)
+
+	labels := rpcLabels("", fullMethod)
+	span.SetAttribute("rpc.system", "grpc")
+	span.SetAttribute("rpc.service", labels["grpc_service"])
+	span.SetAttribute("rpc.method", labels["grpc_method"])
+
+	return ctx, span
+}
+
+func endRPCSpan(span *tracing.Span, err error) {
+	span.SetAttribute("rpc.grpc.status_code", status.Code(err).String())
+	span.SetError(err)
+}
+
+// TracingClientInterceptor creates a client span around outgoing RPCs, and
+// propagates it in their metadata, e.g. with grpc.WithChainUnaryInterceptor.
+func TracingClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := startRPCSpan(ctx, method, tracing.KindClient)
+	defer span.End()
+
+	err := invoker(outgoingTraceContext(ctx), method, req, reply, cc, opts...)
+	endRPCSpan(span, err)
+
+	return err
+}
+
+// StreamTracingClientInterceptor propagates the trace context of outgoing streams,
+// e.g. with grpc.WithChainStreamInterceptor.
+// Unlike TracingClientInterceptor, it doesn't create spans, as streams can outlive
+// the call that creates them.
+func StreamTracingClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingTraceContext(ctx), desc, cc, method, opts...)
+}
+
+// outgoingTraceContext sets the trace context in the outgoing metadata, replacing any.
+func outgoingTraceContext(ctx context.Context) context.Context {
+	traceparent, tracestate, ok := tracing.Inject(ctx)
+	if !ok {
+		return ctx
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(tracing.TraceparentHeader, traceparent)
+	md.Delete(tracing.TracestateHeader)
+	if tracestate != "" {
+		md.Set(tracing.TracestateHeader, tracestate)
+	}
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
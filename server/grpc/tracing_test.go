@@ -0,0 +1,86 @@
+package grpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	grpcsrv "github.com/tscolari/servicetools/server/grpc"
+	"github.com/tscolari/servicetools/tracing"
+)
+
+func Test_TracingInterceptors(t *testing.T) {
+	exporter := tracing.NewMemoryExporter()
+	tracing.SetExporter(exporter)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+
+	// The client interceptor sends the trace context to the server one.
+	var outgoing metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		ctx = metadata.NewIncomingContext(context.Background(), outgoing)
+
+		info := &grpc.UnaryServerInfo{FullMethod: method}
+		_, err := grpcsrv.TracingInterceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+			_, ok := tracing.SpanFromContext(ctx)
+			require.True(t, ok)
+
+			return nil, status.Error(codes.NotFound, "not found")
+		})
+
+		return err
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "invalid", "other", "value")
+	err := grpcsrv.TracingClientInterceptor(ctx, "/test.Service/Get", nil, nil, nil, invoker)
+	require.Equal(t, codes.NotFound, status.Code(err))
+	require.Equal(t, []string{"value"}, outgoing.Get("other"))
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+
+	serverSpan, clientSpan := spans[0], spans[1]
+
+	require.Equal(t, "test.Service/Get", serverSpan.Name)
+	require.Equal(t, tracing.KindServer, serverSpan.Kind)
+	require.Equal(t, "test.Service", serverSpan.Attributes["rpc.service"])
+	require.Equal(t, "Get", serverSpan.Attributes["rpc.method"])
+	require.Equal(t, "NotFound", serverSpan.Attributes["rpc.grpc.status_code"])
+	require.Contains(t, serverSpan.Error, "not found")
+
+	require.Equal(t, tracing.KindClient, clientSpan.Kind)
+	require.Equal(t, clientSpan.TraceID, serverSpan.TraceID)
+	require.Equal(t, clientSpan.SpanID, serverSpan.ParentSpanID)
+	require.Equal(t, []string{"00-" + clientSpan.TraceID + "-" + clientSpan.SpanID + "-01"}, outgoing.Get("traceparent"))
+
+	t.Run("streams", func(t *testing.T) {
+		exporter.Reset()
+
+		incoming := metadata.Pairs("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		stream := &fakeServerStream{ctx: metadata.NewIncomingContext(context.Background(), incoming)}
+		info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Chat"}
+
+		err := grpcsrv.StreamTracingInterceptor(nil, stream, info, func(srv any, stream grpc.ServerStream) error {
+			_, err := grpcsrv.StreamTracingClientInterceptor(stream.Context(), nil, nil, "/other.Service/Chat",
+				func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+					outgoing, _ = metadata.FromOutgoingContext(ctx)
+					return nil, nil
+				},
+			)
+
+			return err
+		})
+		require.NoError(t, err)
+
+		spans := exporter.Spans()
+		require.Len(t, spans, 1)
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceID)
+		require.Equal(t, "00f067aa0ba902b7", spans[0].ParentSpanID)
+		require.Equal(t, []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-" + spans[0].SpanID + "-01"}, outgoing.Get("traceparent"))
+	})
+}
@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/tscolari/servicetools/validations"
+)
+
+// validator is implemented by requests that can validate themselves, e.g. the
+// messages generated by protoc-gen-validate.
+type validator interface {
+	Validate() error
+}
+
+// contextValidator is implemented by requests whose validation needs the context,
+// e.g. to check the database.
+type contextValidator interface {
+	ValidateWithContext(ctx context.Context) error
+}
+
+// ValidationInterceptor rejects requests that implement `Validate() error`, or
+// `ValidateWithContext(context.Context) error`, and fail the validation, before
+// the handler runs.
+// The error is returned as a codes.InvalidArgument error (see validations.Error), with
+// the fields that it refers to, if any, as errdetails.BadRequest field violations.
+func ValidationInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	if err := validate(ctx, req); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// StreamValidationInterceptor validates every message received by the stream, with
+// RecvMsg returning the same errors as ValidationInterceptor.
+// It's the streaming equivalent of ValidationInterceptor.
+func StreamValidationInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &validatingServerStream{ServerStream: stream})
+}
+
+func validate(ctx context.Context, req any) error {
+	switch r := req.(type) {
+	case contextValidator:
+		return validations.Error(r.ValidateWithContext(ctx))
+	case validator:
+		return validations.Error(r.Validate())
+	}
+
+	return nil
+}
+
+type validatingServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return validate(s.Context(), m)
+}
@@ -0,0 +1,129 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	grpcsrv "github.com/tscolari/servicetools/server/grpc"
+	"github.com/tscolari/servicetools/validations"
+)
+
+type validatedRequest struct {
+	name string
+}
+
+func (r validatedRequest) Validate() error {
+	if r.name == "" {
+		return validations.NewFieldError("name", errors.New("is required"))
+	}
+
+	return nil
+}
+
+type contextKey struct{}
+
+type contextValidatedRequest struct{}
+
+func (contextValidatedRequest) Validate() error {
+	panic("ValidateWithContext should be preferred")
+}
+
+func (contextValidatedRequest) ValidateWithContext(ctx context.Context) error {
+	if ctx.Value(contextKey{}) == nil {
+		return errors.New("missing context value")
+	}
+
+	return nil
+}
+
+func Test_ValidationInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Create"}
+
+	handlerCalled := false
+	handler := func(ctx context.Context, req any) (any, error) {
+		handlerCalled = true
+		return req, nil
+	}
+
+	t.Run("invalid requests", func(t *testing.T) {
+		handlerCalled = false
+
+		_, err := grpcsrv.ValidationInterceptor(context.Background(), validatedRequest{}, info, handler)
+		require.False(t, handlerCalled)
+
+		st := status.Convert(err)
+		require.Equal(t, codes.InvalidArgument, st.Code())
+		require.Len(t, st.Details(), 1)
+		require.Equal(t, "name", st.Details()[0].(*errdetails.BadRequest).GetFieldViolations()[0].GetField())
+	})
+
+	t.Run("valid requests", func(t *testing.T) {
+		handlerCalled = false
+
+		_, err := grpcsrv.ValidationInterceptor(context.Background(), validatedRequest{name: "name"}, info, handler)
+		require.NoError(t, err)
+		require.True(t, handlerCalled)
+	})
+
+	t.Run("requests without validation", func(t *testing.T) {
+		handlerCalled = false
+
+		_, err := grpcsrv.ValidationInterceptor(context.Background(), "request", info, handler)
+		require.NoError(t, err)
+		require.True(t, handlerCalled)
+	})
+
+	t.Run("validation with the context", func(t *testing.T) {
+		_, err := grpcsrv.ValidationInterceptor(context.Background(), contextValidatedRequest{}, info, handler)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		ctx := context.WithValue(context.Background(), contextKey{}, true)
+		_, err = grpcsrv.ValidationInterceptor(ctx, contextValidatedRequest{}, info, handler)
+		require.NoError(t, err)
+	})
+}
+
+func Test_StreamValidationInterceptor(t *testing.T) {
+	stream := &fakeStreamOf{messages: []validatedRequest{{name: "first"}, {}}}
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Upload", IsClientStream: true}
+
+	var received []string
+	err := grpcsrv.StreamValidationInterceptor(nil, stream, info, func(srv any, stream grpc.ServerStream) error {
+		for {
+			var msg validatedRequest
+			if err := stream.RecvMsg(&msg); err != nil {
+				return err
+			}
+
+			received = append(received, msg.name)
+		}
+	})
+
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, []string{"first"}, received)
+}
+
+// fakeStreamOf receives the given messages.
+type fakeStreamOf struct {
+	grpc.ServerStream
+	messages []validatedRequest
+}
+
+func (s *fakeStreamOf) Context() context.Context {
+	return context.Background()
+}
+
+func (s *fakeStreamOf) RecvMsg(m any) error {
+	msg := s.messages[0]
+	s.messages = s.messages[1:]
+
+	*(m.(*validatedRequest)) = msg
+	return nil
+}
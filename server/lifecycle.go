@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// State represents the lifecycle state of a component.
+type State int32
+
+const (
+	// StateNew is the state of a component that was never started.
+	StateNew State = iota
+	// StateStarting is the state of a component that is being started.
+	StateStarting
+	// StateRunning is the state of a component that has started and is ready.
+	StateRunning
+	// StateStopping is the state of a component that is being stopped.
+	StateStopping
+	// StateStopped is the state of a component that has stopped cleanly.
+	StateStopped
+	// StateFailed is the state of a component that failed to start, or
+	// that stopped because of an error.
+	StateFailed
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	}
+
+	return "unknown"
+}
+
+var (
+	// ErrNotStarted is returned when stopping a component that was never started.
+	ErrNotStarted = errors.New("component was not started")
+
+	// ErrAlreadyStarted is returned when starting a component that is already starting,
+	// running or stopping.
+	ErrAlreadyStarted = errors.New("component was already started")
+
+	// ErrNotRestartable is returned when starting a stopped component that can't be restarted.
+	ErrNotRestartable = errors.New("component can't be restarted")
+)
+
+// lifecycle tracks the state of a component and guards its transitions.
+// It's safe for concurrent use.
+//
+// Components are expected to call start() at the beginning of Start, running() once
+// they are ready, and finish() when Start returns. Stop should call stop(), and
+// only perform the actual stop if it returns true.
+type lifecycle struct {
+	mutex       *sync.Mutex
+	state       State
+	restartable bool
+
+	// ready is closed when the component is running, and stays closed
+	// after it stops, until it's restarted.
+	ready chan struct{}
+	// settled is closed when the component leaves the starting state.
+	settled chan struct{}
+	// done is closed when Start returns.
+	done chan struct{}
+}
+
+func newLifecycle(restartable bool) *lifecycle {
+	return &lifecycle{
+		mutex:       new(sync.Mutex),
+		state:       StateNew,
+		restartable: restartable,
+		ready:       make(chan struct{}),
+		settled:     make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// State returns the current state.
+func (l *lifecycle) State() State {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.state
+}
+
+// Ready returns a channel that is closed once the component is running.
+// It stays closed after the component stops, so that callers waiting for it
+// don't block forever on a component that already ran, until it's restarted.
+func (l *lifecycle) Ready() <-chan struct{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.ready
+}
+
+// start transitions the component into StateStarting.
+func (l *lifecycle) start() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	switch l.state {
+	case StateNew:
+	case StateStopped, StateFailed:
+		if !l.restartable {
+			return ErrNotRestartable
+		}
+
+		// Callers of Ready() must wait until it is running again.
+		select {
+		case <-l.ready:
+			l.ready = make(chan struct{})
+		default:
+		}
+
+		l.settled = make(chan struct{})
+		l.done = make(chan struct{})
+	default:
+		return ErrAlreadyStarted
+	}
+
+	l.state = StateStarting
+	return nil
+}
+
+// running transitions the component from StateStarting into StateRunning.
+func (l *lifecycle) running() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.state = StateRunning
+	close(l.ready)
+	close(l.settled)
+}
+
+// finish must be called when Start returns. It transitions the component into
+// StateFailed if err is not nil, or StateStopped otherwise.
+// It returns the given err for convenience.
+func (l *lifecycle) finish(err error) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.state == StateStarting {
+		close(l.settled)
+	}
+
+	l.state = StateStopped
+	if err != nil {
+		l.state = StateFailed
+	}
+
+	close(l.done)
+	return err
+}
+
+// stop transitions a running component into StateStopping.
+// It returns true if the caller must perform the stop, or false if
+// there's nothing to be stopped.
+// If the component is still starting, it waits for it to settle first.
+// If the component is already being stopped, it waits for it to finish.
+func (l *lifecycle) stop(ctx context.Context) (bool, error) {
+	for {
+		l.mutex.Lock()
+		state, settled, done := l.state, l.settled, l.done
+
+		switch state {
+		case StateRunning:
+			l.state = StateStopping
+			l.mutex.Unlock()
+			return true, nil
+
+		case StateNew:
+			l.mutex.Unlock()
+			return false, ErrNotStarted
+
+		case StateStopped, StateFailed:
+			l.mutex.Unlock()
+			return false, nil
+		}
+
+		l.mutex.Unlock()
+
+		waitFor := settled
+		if state == StateStopping {
+			waitFor = done
+		}
+
+		select {
+		case <-waitFor:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// wait blocks until Start returns or the context is done.
+func (l *lifecycle) wait(ctx context.Context) error {
+	l.mutex.Lock()
+	done := l.done
+	l.mutex.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
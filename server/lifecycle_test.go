@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Lifecycle(t *testing.T) {
+	t.Run("full cycle", func(t *testing.T) {
+		l := newLifecycle(false)
+		require.Equal(t, StateNew, l.State())
+
+		require.NoError(t, l.start())
+		require.Equal(t, StateStarting, l.State())
+		require.ErrorIs(t, l.start(), ErrAlreadyStarted)
+
+		l.running()
+		require.Equal(t, StateRunning, l.State())
+
+		select {
+		case <-l.Ready():
+		default:
+			require.Fail(t, "ready channel should be closed")
+		}
+
+		shouldStop, err := l.stop(context.Background())
+		require.NoError(t, err)
+		require.True(t, shouldStop)
+		require.Equal(t, StateStopping, l.State())
+		require.ErrorIs(t, l.start(), ErrAlreadyStarted)
+
+		require.NoError(t, l.finish(nil))
+		require.Equal(t, StateStopped, l.State())
+		require.NoError(t, l.wait(context.Background()))
+
+		shouldStop, err = l.stop(context.Background())
+		require.NoError(t, err)
+		require.False(t, shouldStop)
+
+		require.ErrorIs(t, l.start(), ErrNotRestartable)
+	})
+
+	t.Run("stopping before starting", func(t *testing.T) {
+		l := newLifecycle(true)
+
+		shouldStop, err := l.stop(context.Background())
+		require.ErrorIs(t, err, ErrNotStarted)
+		require.False(t, shouldStop)
+	})
+
+	t.Run("failing", func(t *testing.T) {
+		l := newLifecycle(true)
+		require.NoError(t, l.start())
+
+		failure := errors.New("failed")
+		require.ErrorIs(t, l.finish(failure), failure)
+		require.Equal(t, StateFailed, l.State())
+
+		shouldStop, err := l.stop(context.Background())
+		require.NoError(t, err)
+		require.False(t, shouldStop)
+	})
+
+	t.Run("restarting", func(t *testing.T) {
+		l := newLifecycle(true)
+		require.NoError(t, l.start())
+		l.running()
+		firstReady := l.Ready()
+		require.NoError(t, l.finish(nil))
+
+		// Ready stays closed until the component is restarted.
+		require.Equal(t, firstReady, l.Ready())
+		require.NoError(t, l.start())
+		require.Equal(t, StateStarting, l.State())
+		require.NotEqual(t, firstReady, l.Ready())
+
+		select {
+		case <-l.Ready():
+			require.Fail(t, "ready channel should not be closed while restarting")
+		default:
+		}
+
+		l.running()
+		require.Equal(t, StateRunning, l.State())
+	})
+
+	t.Run("stopping while starting waits for it to settle", func(t *testing.T) {
+		l := newLifecycle(true)
+		require.NoError(t, l.start())
+
+		stopped := make(chan bool)
+		go func() {
+			shouldStop, err := l.stop(context.Background())
+			require.NoError(t, err)
+			stopped <- shouldStop
+		}()
+
+		select {
+		case <-stopped:
+			require.Fail(t, "stop should wait for start to settle")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		l.running()
+		require.True(t, <-stopped)
+		require.Equal(t, StateStopping, l.State())
+	})
+
+	t.Run("stopping while starting respects the context", func(t *testing.T) {
+		l := newLifecycle(true)
+		require.NoError(t, l.start())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		shouldStop, err := l.stop(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.False(t, shouldStop)
+	})
+}
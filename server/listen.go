@@ -0,0 +1,187 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Address schemes supported by the components, besides plain TCP addresses (e.g. "localhost:8080"):
+const (
+	// unixScheme listens on a unix socket, e.g. "unix:///run/service.sock".
+	unixScheme = "unix://"
+
+	// fdScheme uses an inherited file descriptor, e.g. "fd://3".
+	fdScheme = "fd://"
+
+	// systemdScheme uses a socket passed by systemd socket activation, by its
+	// name (FileDescriptorName), e.g. "systemd://grpc".
+	systemdScheme = "systemd://"
+)
+
+// listen creates a listener for the given address, which can be a TCP address
+// or use one of the unix://, fd:// or systemd:// schemes.
+func listen(address string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, unixScheme):
+		return listenUnix(strings.TrimPrefix(address, unixScheme))
+
+	case strings.HasPrefix(address, fdScheme):
+		fd, err := strconv.Atoi(strings.TrimPrefix(address, fdScheme))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid file descriptor in %q", address)
+		}
+
+		return fileListener(inheritedFile(uintptr(fd), address))
+
+	case strings.HasPrefix(address, systemdScheme):
+		name := strings.TrimPrefix(address, systemdScheme)
+
+		files, err := systemdFiles()
+		if err != nil {
+			return nil, err
+		}
+
+		file, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("no socket named %q was passed by systemd", name)
+		}
+
+		return fileListener(file)
+	}
+
+	return net.Listen("tcp", address)
+}
+
+// listenerAddress returns the address to listen to again, after being stopped,
+// to get the same listener, e.g. the port assigned to "localhost:0".
+func listenerAddress(address string, listener net.Listener) string {
+	switch listener.Addr().Network() {
+	case "tcp":
+		if !strings.Contains(address, "://") {
+			return listener.Addr().String()
+		}
+	case "unix":
+		if strings.HasPrefix(address, unixScheme) {
+			return unixScheme + listener.Addr().String()
+		}
+	}
+
+	return address
+}
+
+// listenUnix listens on a unix socket in the given path.
+// A socket file left behind by a previous process is removed first, unless
+// something is still listening on it.
+func listenUnix(path string) (net.Listener, error) {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+
+	case err != nil:
+		return nil, fmt.Errorf("failed to check socket file: %w", err)
+
+	case info.Mode().Type() != fs.ModeSocket:
+		return nil, fmt.Errorf("%q exists and is not a socket", path)
+
+	default:
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("socket %q is in use", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	// The socket file is removed when the listener is closed.
+	return net.Listen("unix", path)
+}
+
+// fileListener creates a listener from a copy of the given file descriptor,
+// so that the file can be used again after the listener is closed.
+func fileListener(file *os.File) (net.Listener, error) {
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", file.Name(), err)
+	}
+
+	return listener, nil
+}
+
+// inheritedFiles holds the files created for inherited file descriptors, as
+// closing them (e.g. when garbage collected) would close the descriptors.
+var (
+	inheritedFilesMutex sync.Mutex
+	inheritedFiles      = map[uintptr]*os.File{}
+)
+
+func inheritedFile(fd uintptr, name string) *os.File {
+	inheritedFilesMutex.Lock()
+	defer inheritedFilesMutex.Unlock()
+
+	file, ok := inheritedFiles[fd]
+	if !ok {
+		file = os.NewFile(fd, name)
+		inheritedFiles[fd] = file
+	}
+
+	return file
+}
+
+// systemdFirstFD is the first file descriptor passed by systemd (SD_LISTEN_FDS_START).
+const systemdFirstFD = 3
+
+// systemdFiles returns the sockets passed by systemd socket activation, by name.
+// Sockets without a name (see FileDescriptorName in systemd.socket) are named after
+// their file descriptor, e.g. "3".
+func systemdFiles() (map[string]*os.File, error) {
+	if pid := os.Getenv("LISTEN_PID"); pid != strconv.Itoa(os.Getpid()) {
+		return nil, errors.New("no sockets were passed by systemd to this process")
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	files := map[string]*os.File{}
+
+	for i := 0; i < count; i++ {
+		fd := systemdFirstFD + i
+
+		name := strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" && names[i] != "unknown" {
+			name = names[i]
+		}
+
+		files[name] = inheritedFile(uintptr(fd), systemdScheme+name)
+	}
+
+	return files, nil
+}
+
+// givenListener is a listener given to a component, instead of an address.
+type givenListener struct {
+	listener net.Listener
+	used     bool
+}
+
+// take returns the listener, which can only be used once, as it's closed
+// when the component stops.
+func (g *givenListener) take() (net.Listener, error) {
+	if g.used {
+		return nil, errors.New("the given listener was already used and closed")
+	}
+
+	g.used = true
+	return g.listener, nil
+}
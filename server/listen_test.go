@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Listen(t *testing.T) {
+	helloHTTP := func(address string) *WithHTTP {
+		withHTTP := NewWithHTTP(address)
+		withHTTP.Register(func(handle func(string, func(http.ResponseWriter, *http.Request))) {
+			handle("/hello", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("hello"))
+			})
+		})
+
+		return withHTTP
+	}
+
+	get := func(t *testing.T, client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	unixClient := func(path string) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}}
+	}
+
+	t.Run("unix sockets", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "http.sock")
+		withHTTP := helloHTTP("unix://" + path)
+
+		go func() {
+			require.NoError(t, withHTTP.Start(context.Background(), slog.Default()))
+		}()
+		<-withHTTP.Ready()
+
+		require.Equal(t, "unix://"+path, withHTTP.address)
+		require.Equal(t, "hello", get(t, unixClient(path), "http://unix/hello"))
+
+		require.NoError(t, withHTTP.Stop(context.Background(), slog.Default()))
+		require.NoFileExists(t, path)
+	})
+
+	t.Run("stale unix sockets are removed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "http.sock")
+
+		stale, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		require.NoError(t, stale.Close())
+		require.FileExists(t, path)
+
+		listener, err := listen("unix://" + path)
+		require.NoError(t, err)
+		require.NoError(t, listener.Close())
+	})
+
+	t.Run("unix sockets in use are not removed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "http.sock")
+
+		listener, err := listen("unix://" + path)
+		require.NoError(t, err)
+		defer listener.Close()
+
+		_, err = listen("unix://" + path)
+		require.ErrorContains(t, err, "is in use")
+	})
+
+	t.Run("files that are not sockets are not removed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "http.sock")
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+		_, err := listen("unix://" + path)
+		require.ErrorContains(t, err, "is not a socket")
+		require.FileExists(t, path)
+	})
+
+	t.Run("inherited file descriptors", func(t *testing.T) {
+		tcpListener, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		defer tcpListener.Close()
+
+		file, err := tcpListener.(*net.TCPListener).File()
+		require.NoError(t, err)
+
+		withHTTP := helloHTTP(fmt.Sprintf("fd://%d", file.Fd()))
+		startTestComponent(t, withHTTP)
+
+		require.Equal(t, "hello", get(t, http.DefaultClient, "http://"+tcpListener.Addr().String()+"/hello"))
+	})
+
+	t.Run("systemd sockets for another process", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		t.Setenv("LISTEN_FDS", "1")
+
+		_, err := listen("systemd://http")
+		require.ErrorContains(t, err, "no sockets were passed by systemd")
+	})
+
+	t.Run("given listeners", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+
+		withHTTP := helloHTTP("unused:1")
+		withHTTP.UseListener(listener)
+
+		go func() {
+			require.NoError(t, withHTTP.Start(context.Background(), slog.Default()))
+		}()
+		<-withHTTP.Ready()
+
+		require.Equal(t, "hello", get(t, http.DefaultClient, "http://"+listener.Addr().String()+"/hello"))
+		require.NoError(t, withHTTP.Stop(context.Background(), slog.Default()))
+
+		t.Run("can't be restarted", func(t *testing.T) {
+			err := withHTTP.Start(context.Background(), slog.Default())
+			require.ErrorContains(t, err, "the given listener was already used")
+		})
+	})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// ServeWithHTTP makes the gRPC server share the listener of the given HTTP server,
+// instead of listening to its own address.
+// HTTP/2 requests with the `application/grpc` content type are routed to the gRPC
+// server, and everything else to the HTTP handlers. Without TLS, HTTP/2 is served
+// in cleartext (h2c).
+// Both components must still be started and stopped, and it must be called before Start.
+func (s *WithGRPC) ServeWithHTTP(withHTTP *WithHTTP) {
+	shared := &sharedGRPC{mutex: new(sync.Mutex)}
+
+	s.mutex.Lock()
+	s.shared = shared
+	s.mutex.Unlock()
+
+	withHTTP.mutex.Lock()
+	withHTTP.grpc = shared
+	withHTTP.mutex.Unlock()
+}
+
+// sharedGRPC routes the gRPC requests received by a WithHTTP to the gRPC server,
+// while it's running.
+type sharedGRPC struct {
+	mutex    *sync.Mutex
+	server   *grpc.Server
+	inFlight sync.WaitGroup
+	stopped  chan struct{}
+}
+
+// serve starts routing requests to the given server, until stop is called.
+// The returned channel is closed once it's stopped.
+func (g *sharedGRPC) serve(server *grpc.Server) <-chan struct{} {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.server = server
+	g.stopped = make(chan struct{})
+
+	return g.stopped
+}
+
+// drain stops routing new requests to the server, and waits for the in-flight ones to finish.
+// grpc.Server.GracefulStop can't be used, as it doesn't support requests served by ServeHTTP.
+func (g *sharedGRPC) drain() {
+	g.mutex.Lock()
+	g.server = nil
+	g.mutex.Unlock()
+
+	g.inFlight.Wait()
+}
+
+// stop releases the server, after it was drained or stopped.
+func (g *sharedGRPC) stop() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	close(g.stopped)
+}
+
+// handler returns a handler that routes gRPC requests to the server and
+// the others to the given handler.
+func (g *sharedGRPC) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		g.mutex.Lock()
+		server := g.server
+		if server != nil {
+			g.inFlight.Add(1)
+		}
+		g.mutex.Unlock()
+
+		if server == nil {
+			// gRPC clients see it as codes.Unavailable.
+			http.Error(w, "grpc server is not running", http.StatusServiceUnavailable)
+			return
+		}
+
+		defer g.inFlight.Done()
+		server.ServeHTTP(w, r)
+	})
+}
+
+// sharedHandler returns the handler of an HTTP server that shares its listener with
+// the gRPC server, with h2c support when not using TLS.
+// HTTP/2 connections get a GOAWAY when the HTTP server shuts down.
+func (g *sharedGRPC) sharedHandler(server *http.Server, next http.Handler) (http.Handler, error) {
+	handler := g.handler(next)
+	if server.TLSConfig != nil {
+		return handler, nil
+	}
+
+	h2Server := &http2.Server{}
+	if err := http2.ConfigureServer(server, h2Server); err != nil {
+		return nil, err
+	}
+
+	return h2c.NewHandler(handler, h2Server), nil
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func Test_ServeWithHTTP(t *testing.T) {
+	newSharedServers := func(t *testing.T) (*WithGRPC, *WithHTTP) {
+		withHTTP := NewWithHTTP("localhost:0")
+		withHTTP.Register(func(handle func(string, func(http.ResponseWriter, *http.Request))) {
+			handle("/hello", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("hello"))
+			})
+		})
+
+		withGRPC := NewWithGRPC("localhost:0")
+		withGRPC.Register(func(r grpc.ServiceRegistrar) {
+			grpc_health_v1.RegisterHealthServer(r, health.NewServer())
+		})
+		withGRPC.ServeWithHTTP(withHTTP)
+
+		return withGRPC, withHTTP
+	}
+
+	dial := func(t *testing.T, address string) grpc_health_v1.HealthClient {
+		conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+
+		return grpc_health_v1.NewHealthClient(conn)
+	}
+
+	t.Run("serves both on the HTTP listener", func(t *testing.T) {
+		withGRPC, withHTTP := newSharedServers(t)
+		startTestComponent(t, withHTTP)
+		startTestComponent(t, withGRPC)
+
+		resp, err := http.Get("http://" + withHTTP.address + "/hello")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, "hello", string(body))
+
+		_, err = dial(t, withHTTP.address).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+	})
+
+	t.Run("gRPC is unavailable while stopped", func(t *testing.T) {
+		withGRPC, withHTTP := newSharedServers(t)
+		startTestComponent(t, withHTTP)
+
+		_, err := dial(t, withHTTP.address).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.Equal(t, codes.Unavailable, status.Code(err))
+
+		go func() {
+			require.NoError(t, withGRPC.Start(context.Background(), slog.Default()))
+		}()
+		<-withGRPC.Ready()
+
+		_, err = dial(t, withHTTP.address).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		require.NoError(t, withGRPC.Stop(context.Background(), slog.Default()))
+
+		_, err = dial(t, withHTTP.address).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("stopping waits for in-flight RPCs", func(t *testing.T) {
+		withGRPC, withHTTP := newSharedServers(t)
+		startTestComponent(t, withHTTP)
+
+		go func() {
+			require.NoError(t, withGRPC.Start(context.Background(), slog.Default()))
+		}()
+		<-withGRPC.Ready()
+
+		// Watch blocks until the stream is canceled.
+		stream, err := dial(t, withHTTP.address).Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err = withGRPC.Stop(ctx, slog.Default())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorContains(t, err, "grpc server was forced to stop")
+
+		_, err = stream.Recv()
+		require.Error(t, err)
+	})
+}
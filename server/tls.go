@@ -0,0 +1,270 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// tlsCheckInterval is how often, at most, the certificate files are checked for changes.
+const tlsCheckInterval = time.Second
+
+// NewTLSCertificates loads the certificate and key from the given files, to be used
+// by the gRPC, HTTP or metrics servers (see their UseTLS methods).
+// If clientCAFile is given, clients must present a certificate signed by one of its
+// CAs (mutual TLS), and their identities are available with PeerIdentityFromContext.
+// The files are reloaded automatically when they change on disk.
+func NewTLSCertificates(certFile, keyFile, clientCAFile string) (*TLSCertificates, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both the TLS certificate and key files must be given")
+	}
+
+	c := &TLSCertificates{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		mutex:        new(sync.Mutex),
+	}
+
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// TLSCertificates holds the server certificate, and optionally the client CAs,
+// loaded from files.
+// Changes to the files are picked up on new connections, which keep using the
+// previous certificates if the new ones fail to load.
+type TLSCertificates struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mutex       *sync.Mutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modTimes    map[string]time.Time
+	checkedAt   time.Time
+}
+
+// MutualTLS returns true if clients must present a certificate.
+func (c *TLSCertificates) MutualTLS() bool {
+	return c.clientCAFile != ""
+}
+
+// Reload loads the files again, even if they didn't change.
+func (c *TLSCertificates) Reload() error {
+	modTimes, err := c.readModTimes()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if c.clientCAFile != "" {
+		content, err := os.ReadFile(c.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS client CA: %w", err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(content) {
+			return fmt.Errorf("no certificates found in TLS client CA %q", c.clientCAFile)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.certificate = &certificate
+	c.clientCAs = clientCAs
+	c.modTimes = modTimes
+	c.checkedAt = time.Now()
+
+	return nil
+}
+
+// Config returns the TLS configuration for a server, always using the current certificates.
+func (c *TLSCertificates) Config() *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			c.reloadIfChanged()
+
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			return c.certificate, nil
+		},
+	}
+
+	if c.MutualTLS() {
+		// The client certificates are verified against the current CAs,
+		// instead of the static tls.Config.ClientCAs.
+		config.ClientAuth = tls.RequireAnyClientCert
+		config.VerifyPeerCertificate = c.verifyClient
+	}
+
+	return config
+}
+
+func (c *TLSCertificates) verifyClient(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("failed to parse client certificate: %w", err)
+		}
+		certs[i] = cert
+	}
+
+	if len(certs) == 0 {
+		return errors.New("no client certificate given")
+	}
+
+	c.mutex.Lock()
+	roots := c.clientCAs
+	c.mutex.Unlock()
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	if _, err := certs[0].Verify(opts); err != nil {
+		return fmt.Errorf("failed to verify client certificate: %w", err)
+	}
+
+	return nil
+}
+
+// reloadIfChanged reloads the files if any of them changed since they were loaded.
+// Errors are ignored, so that the previous certificates keep being used.
+func (c *TLSCertificates) reloadIfChanged() {
+	c.mutex.Lock()
+	if time.Since(c.checkedAt) < tlsCheckInterval {
+		c.mutex.Unlock()
+		return
+	}
+
+	c.checkedAt = time.Now()
+	loaded := c.modTimes
+	c.mutex.Unlock()
+
+	modTimes, err := c.readModTimes()
+	if err != nil {
+		return
+	}
+
+	for file, modTime := range modTimes {
+		if !modTime.Equal(loaded[file]) {
+			_ = c.Reload()
+			return
+		}
+	}
+}
+
+func (c *TLSCertificates) readModTimes() (map[string]time.Time, error) {
+	modTimes := map[string]time.Time{}
+
+	for _, file := range []string{c.certFile, c.keyFile, c.clientCAFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS file: %w", err)
+		}
+
+		modTimes[file] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+// PeerIdentity identifies a client by its (verified) TLS certificate.
+type PeerIdentity struct {
+	// Subject is the distinguished name of the certificate subject, e.g. "CN=client,O=Org".
+	Subject string
+
+	// CommonName is the common name of the certificate subject.
+	CommonName string
+
+	// Subject alternative names:
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+}
+
+type peerIdentityKey struct{}
+
+// PeerIdentityFromContext returns the identity of the client that made the request,
+// in gRPC and HTTP handlers of servers using mutual TLS.
+// It returns false if the client didn't present a certificate.
+func PeerIdentityFromContext(ctx context.Context) (*PeerIdentity, bool) {
+	if identity, ok := ctx.Value(peerIdentityKey{}).(*PeerIdentity); ok {
+		return identity, true
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			return peerIdentity(tlsInfo.State)
+		}
+	}
+
+	return nil, false
+}
+
+func peerIdentity(state tls.ConnectionState) (*PeerIdentity, bool) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, false
+	}
+
+	cert := state.PeerCertificates[0]
+
+	return &PeerIdentity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		IPAddresses:    cert.IPAddresses,
+		URIs:           cert.URIs,
+	}, true
+}
+
+// withPeerIdentity adds the identity of the client to the context of the requests,
+// so that it's available with PeerIdentityFromContext.
+func withPeerIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			if identity, ok := peerIdentity(*r.TLS); ok {
+				r = r.WithContext(context.WithValue(r.Context(), peerIdentityKey{}, identity))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
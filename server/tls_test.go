@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func Test_TLSCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+
+	certFile, keyFile := ca.writeCert(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	caFile := ca.writeCA(t, dir)
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := NewTLSCertificates(certFile, "", "")
+		require.Error(t, err)
+	})
+
+	t.Run("invalid client CA", func(t *testing.T) {
+		_, err := NewTLSCertificates(certFile, keyFile, keyFile)
+		require.ErrorContains(t, err, "no certificates found")
+	})
+
+	t.Run("HTTP with mutual TLS", func(t *testing.T) {
+		certificates, err := NewTLSCertificates(certFile, keyFile, caFile)
+		require.NoError(t, err)
+
+		var identity *PeerIdentity
+		withHTTP := NewWithHTTP("localhost:0")
+		withHTTP.UseTLS(certificates)
+		withHTTP.Register(func(handle func(string, func(http.ResponseWriter, *http.Request))) {
+			handle("/whoami", func(w http.ResponseWriter, r *http.Request) {
+				identity, _ = PeerIdentityFromContext(r.Context())
+			})
+		})
+
+		startTestComponent(t, withHTTP)
+
+		clientCert, clientKey := ca.writeCert(t, t.TempDir(), "client", x509.ExtKeyUsageClientAuth)
+		client := ca.httpClient(t, clientCert, clientKey)
+
+		resp, err := client.Get("https://" + withHTTP.address + "/whoami")
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, resp.Body.Close())
+
+		require.NotNil(t, identity)
+		require.Equal(t, "client", identity.CommonName)
+		require.Equal(t, []string{"client.test"}, identity.DNSNames)
+
+		t.Run("clients without certificates are refused", func(t *testing.T) {
+			_, err := ca.httpClient(t, "", "").Get("https://" + withHTTP.address + "/whoami")
+			require.Error(t, err)
+		})
+
+		t.Run("clients with certificates from other CAs are refused", func(t *testing.T) {
+			otherCert, otherKey := newTestCA(t).writeCert(t, t.TempDir(), "other", x509.ExtKeyUsageClientAuth)
+			_, err := ca.httpClient(t, otherCert, otherKey).Get("https://" + withHTTP.address + "/whoami")
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("gRPC with mutual TLS", func(t *testing.T) {
+		certificates, err := NewTLSCertificates(certFile, keyFile, caFile)
+		require.NoError(t, err)
+
+		var identity *PeerIdentity
+		withGRPC := NewWithGRPC("localhost:0", grpc.UnaryInterceptor(
+			func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				identity, _ = PeerIdentityFromContext(ctx)
+				return handler(ctx, req)
+			},
+		))
+		withGRPC.UseTLS(certificates)
+		withGRPC.Register(func(r grpc.ServiceRegistrar) {
+			grpc_health_v1.RegisterHealthServer(r, health.NewServer())
+		})
+
+		startTestComponent(t, withGRPC)
+
+		clientCert, clientKey := ca.writeCert(t, t.TempDir(), "client", x509.ExtKeyUsageClientAuth)
+		conn, err := grpc.Dial(withGRPC.address, grpc.WithTransportCredentials(
+			credentials.NewTLS(ca.clientConfig(t, clientCert, clientKey)),
+		))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		require.NotNil(t, identity)
+		require.Equal(t, "client", identity.CommonName)
+	})
+
+	t.Run("reloading changed files", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := ca.writeCert(t, dir, "before", x509.ExtKeyUsageServerAuth)
+
+		certificates, err := NewTLSCertificates(certFile, keyFile, "")
+		require.NoError(t, err)
+
+		getCertificate := certificates.Config().GetCertificate
+		cert, err := getCertificate(nil)
+		require.NoError(t, err)
+		require.Equal(t, "before", cert.Leaf.Subject.CommonName)
+
+		newCertFile, newKeyFile := ca.writeCert(t, t.TempDir(), "after", x509.ExtKeyUsageServerAuth)
+		require.NoError(t, os.Rename(newCertFile, certFile))
+		require.NoError(t, os.Rename(newKeyFile, keyFile))
+
+		// Files are checked at most once per tlsCheckInterval.
+		future := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(certFile, future, future))
+		certificates.checkedAt = time.Time{}
+
+		cert, err = getCertificate(nil)
+		require.NoError(t, err)
+		require.Equal(t, "after", cert.Leaf.Subject.CommonName)
+	})
+}
+
+func startTestComponent(t *testing.T, component Component) {
+	go func() {
+		require.NoError(t, component.Start(context.Background(), slog.Default()))
+	}()
+
+	select {
+	case <-component.Ready():
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for component to start")
+	}
+
+	t.Cleanup(func() {
+		require.NoError(t, component.Stop(context.Background(), slog.Default()))
+	})
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, der: der}
+}
+
+func (ca *testCA) writeCA(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der}), 0o600))
+	return path
+}
+
+// writeCert writes a certificate, signed by the CA, valid for localhost and "<name>.test".
+func (ca *testCA) writeCert(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name + ".test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+// clientConfig returns a TLS configuration trusting the CA, with the given client certificate, if any.
+func (ca *testCA) clientConfig(t *testing.T, certFile, keyFile string) *tls.Config {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	config := &tls.Config{RootCAs: roots}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		require.NoError(t, err)
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config
+}
+
+func (ca *testCA) httpClient(t *testing.T, certFile, keyFile string) *http.Client {
+	return &http.Client{
+		Timeout:   time.Second,
+		Transport: &http.Transport{TLSClientConfig: ca.clientConfig(t, certFile, keyFile)},
+	}
+}
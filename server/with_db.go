@@ -4,19 +4,28 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"sync"
 
 	"github.com/tscolari/servicetools/database"
 )
 
 // NewWithDB returns a WithDB object configured with the given config.
-func NewWithDB(config *database.Config) (*WithDB, error) {
-	db, err := openDB(config)
+// Connection attempts are retried according to the config (see database.Open),
+// and logged using the given logger.
+func NewWithDB(ctx context.Context, logger *slog.Logger, config *database.Config) (*WithDB, error) {
+	db, err := database.Open(ctx, logger, config)
 	if err != nil {
 		return nil, fmt.Errorf("database initialization failed: %w", err)
 	}
 
 	return &WithDB{
-		BaseDB: db,
+		config:    *config,
+		mutex:     new(sync.RWMutex),
+		BaseDB:    db,
+		lifecycle: newLifecycle(false),
+		stopChan:  make(chan struct{}),
 	}, nil
 }
 
@@ -27,16 +36,139 @@ type WithDB struct {
 	// This is not safe to be used directly, and it's exposed only for
 	// the purposes of making testing (and modifications) easier.
 	BaseDB *sql.DB
+
+	*lifecycle
+	stopChan chan struct{}
+
+	// mutex guards the swapping of BaseDB in Reconnect.
+	mutex  *sync.RWMutex
+	config database.Config
+
+	migrations    fs.FS
+	migrationsDir string
 }
 
 // DB returns an usable database object.
 // If no database configured, it will return nil.
 func (s *WithDB) DB(ctx context.Context) *sql.DB {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.BaseDB
+}
+
+// MigrateOnStart makes Start migrate the database, using the migrations
+// from the dir of the given filesystem, before the component is ready.
+// See database.MigrateWithLock.
+func (s *WithDB) MigrateOnStart(fsys fs.FS, dir string) {
+	s.migrations = fsys
+	s.migrationsDir = dir
+}
+
+var _ Component = &WithDB{}
+
+// Name returns the name of the component.
+func (s *WithDB) Name() string {
+	return "database"
+}
+
+// Start marks the database as ready and blocks until it is stopped.
+// The connection is opened when the object is created, so there's
+// nothing else to be started, unless MigrateOnStart was used, in which
+// case the database is migrated first.
+// Once stopped, the connection is closed and it can't be restarted.
+func (s *WithDB) Start(ctx context.Context, logger *slog.Logger) error {
+	if err := s.lifecycle.start(); err != nil {
+		return err
+	}
+
+	if s.migrations != nil {
+		logger.Info("migrating database")
+
+		if err := database.MigrateWithLock(ctx, s.BaseDB, s.migrations, s.migrationsDir); err != nil {
+			_ = s.Close()
+			return s.lifecycle.finish(fmt.Errorf("failed to migrate database: %w", err))
+		}
+
+		logger.Info("database migrated")
+	}
+
+	s.lifecycle.running()
+
+	select {
+	case <-ctx.Done():
+	case <-s.stopChan:
+	}
+
+	return s.lifecycle.finish(nil)
+}
+
+// Stop closes the underlying database connection, causing Start to return.
+// It returns ErrNotStarted if the component was never started, in which case
+// Close should be used to close the connection.
+func (s *WithDB) Stop(ctx context.Context, logger *slog.Logger) error {
+	shouldStop, err := s.lifecycle.stop(ctx)
+	if !shouldStop {
+		return err
+	}
+
+	close(s.stopChan)
+	if err := s.lifecycle.wait(ctx); err != nil {
+		return err
+	}
+
+	return s.Close()
+}
+
+// Close closes the underlying database connection.
+func (s *WithDB) Close() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	if s.BaseDB == nil {
 		return nil
 	}
 
-	return s.BaseDB
+	return s.BaseDB.Close()
+}
+
+// Reconnect opens a new connection pool using the given config, e.g. with rotated
+// credentials, and replaces the current one with it.
+// The previous pool is closed once its in-flight queries finish, so callers
+// must get the connection from DB() on every use, instead of holding on to it.
+// Nothing is done if the config didn't change.
+func (s *WithDB) Reconnect(ctx context.Context, logger *slog.Logger, config *database.Config) error {
+	s.mutex.RLock()
+	unchanged := s.config == *config
+	s.mutex.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	db, err := database.Open(ctx, logger, config)
+	if err != nil {
+		return fmt.Errorf("database reconnection failed: %w", err)
+	}
+
+	s.mutex.Lock()
+	previous := s.BaseDB
+	s.BaseDB = db
+	s.config = *config
+	s.mutex.Unlock()
+
+	logger.Info("database connection pool replaced", "component", s.Name())
+
+	if previous != nil {
+		// Close blocks until in-flight queries finish.
+		go func() {
+			if err := previous.Close(); err != nil {
+				logger.Error("failed to close previous database connection pool", "component", s.Name(), "error", err)
+			}
+		}()
+	}
+
+	return nil
 }
 
 // ConfigureDatabase is the hook used by the cmd package to inject the
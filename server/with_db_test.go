@@ -2,7 +2,10 @@ package server
 
 import (
 	"context"
+	"io/fs"
+	"log/slog"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/require"
 	"github.com/tscolari/servicetools/database"
@@ -26,7 +29,7 @@ func Test_WithDB(t *testing.T) {
 		SSLMode:  false,
 	}
 
-	withDB, err := NewWithDB(&config)
+	withDB, err := NewWithDB(context.Background(), slog.Default(), &config)
 	require.NoError(t, err)
 
 	testObj := &testWithDB{}
@@ -49,7 +52,136 @@ func Test_WithDB(t *testing.T) {
 			SSLMode:  false,
 		}
 
-		_, err := NewWithDB(&config)
+		_, err := NewWithDB(context.Background(), slog.Default(), &config)
 		require.Error(t, err)
 	})
+
+	t.Run("migrating on start", func(t *testing.T) {
+		withDB, err := NewWithDB(context.Background(), slog.Default(), &config)
+		require.NoError(t, err)
+
+		db := withDB.DB(context.Background())
+		t.Cleanup(func() {
+			_, _ = db.Exec(`DROP TABLE IF EXISTS servicetools_with_db_test, schema_migrations`)
+		})
+
+		withDB.MigrateOnStart(fstest.MapFS{
+			"migrations/1_create.up.sql":   &fstest.MapFile{Data: []byte(`CREATE TABLE servicetools_with_db_test (id TEXT)`)},
+			"migrations/1_create.down.sql": &fstest.MapFile{Data: []byte(`DROP TABLE servicetools_with_db_test`)},
+		}, "migrations")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() { _ = withDB.Start(ctx, slog.Default()) }()
+		<-withDB.Ready()
+
+		_, err = db.Exec(`SELECT * FROM servicetools_with_db_test`)
+		require.NoError(t, err)
+		require.NoError(t, withDB.Stop(context.Background(), slog.Default()))
+
+		t.Run("when the schema is newer than the migrations", func(t *testing.T) {
+			withDB, err := NewWithDB(context.Background(), slog.Default(), &config)
+			require.NoError(t, err)
+
+			withDB.MigrateOnStart(fstest.MapFS{"migrations": &fstest.MapFile{Mode: fs.ModeDir}}, "migrations")
+
+			err = withDB.Start(context.Background(), slog.Default())
+			require.ErrorIs(t, err, database.ErrUnknownSchemaVersion)
+			require.Equal(t, StateFailed, withDB.State())
+		})
+	})
+
+	t.Run("managing migrations", func(t *testing.T) {
+		t.Cleanup(func() {
+			_, _ = db.Exec(`DROP TABLE IF EXISTS servicetools_migrations_a, servicetools_migrations_b, schema_migrations`)
+		})
+
+		fsys := fstest.MapFS{
+			"migrations/1_create_a.up.sql":   &fstest.MapFile{Data: []byte(`CREATE TABLE servicetools_migrations_a (id TEXT)`)},
+			"migrations/1_create_a.down.sql": &fstest.MapFile{Data: []byte(`DROP TABLE servicetools_migrations_a`)},
+			"migrations/2_create_b.up.sql":   &fstest.MapFile{Data: []byte(`CREATE TABLE servicetools_migrations_b (id TEXT)`)},
+			"migrations/2_create_b.down.sql": &fstest.MapFile{Data: []byte(`DROP TABLE servicetools_migrations_b`)},
+		}
+
+		requireStatus := func(t *testing.T, version uint, pending ...string) {
+			status, err := database.ReadMigrationStatusFS(db, fsys, "migrations")
+			require.NoError(t, err)
+			require.Equal(t, version, status.Version)
+			require.False(t, status.Dirty)
+			require.Equal(t, uint(2), status.Latest)
+			require.Equal(t, pending, status.Pending)
+		}
+
+		requireStatus(t, 0, "1_create_a", "2_create_b")
+
+		require.NoError(t, database.MigrateToFS(db, fsys, "migrations", 1))
+		requireStatus(t, 1, "2_create_b")
+
+		require.NoError(t, database.MigrateFS(db, fsys, "migrations"))
+		requireStatus(t, 2)
+
+		_, err := db.Exec(`SELECT * FROM servicetools_migrations_b`)
+		require.NoError(t, err)
+
+		require.NoError(t, database.MigrateDownFS(db, fsys, "migrations", 1))
+		requireStatus(t, 1, "2_create_b")
+
+		_, err = db.Exec(`SELECT * FROM servicetools_migrations_b`)
+		require.Error(t, err)
+
+		require.NoError(t, database.MigrateDownFS(db, fsys, "migrations", 0))
+		requireStatus(t, 0, "1_create_a", "2_create_b")
+
+		// Forcing sets the version without running the migrations.
+		require.NoError(t, database.ForceMigrationFS(db, fsys, "migrations", 2))
+		requireStatus(t, 2)
+
+		_, err = db.Exec(`SELECT * FROM servicetools_migrations_a`)
+		require.Error(t, err)
+
+		require.NoError(t, database.ForceMigrationFS(db, fsys, "migrations", -1))
+		requireStatus(t, 0, "1_create_a", "2_create_b")
+	})
+
+	t.Run("migrating with a lock", func(t *testing.T) {
+		t.Cleanup(func() {
+			_, _ = db.Exec(`DROP TABLE IF EXISTS servicetools_migrations_lock, schema_migrations`)
+		})
+
+		fsys := fstest.MapFS{
+			"migrations/1_create.up.sql":   &fstest.MapFile{Data: []byte(`CREATE TABLE servicetools_migrations_lock (id TEXT)`)},
+			"migrations/1_create.down.sql": &fstest.MapFile{Data: []byte(`DROP TABLE servicetools_migrations_lock`)},
+		}
+
+		// Concurrent callers wait for each other instead of failing.
+		errs := make(chan error, 3)
+		for i := 0; i < cap(errs); i++ {
+			go func() {
+				errs <- database.MigrateWithLock(context.Background(), db, fsys, "migrations")
+			}()
+		}
+
+		for i := 0; i < cap(errs); i++ {
+			require.NoError(t, <-errs)
+		}
+
+		status, err := database.ReadMigrationStatusFS(db, fsys, "migrations")
+		require.NoError(t, err)
+		require.Equal(t, uint(1), status.Version)
+		require.Empty(t, status.Pending)
+
+		// A failed migration leaves the database dirty, which must be fixed first.
+		fsys["migrations/2_fail.up.sql"] = &fstest.MapFile{Data: []byte(`NOT SQL`)}
+		fsys["migrations/2_fail.down.sql"] = &fstest.MapFile{Data: []byte(`SELECT 1`)}
+
+		require.Error(t, database.MigrateWithLock(context.Background(), db, fsys, "migrations"))
+		require.ErrorContains(t, database.MigrateWithLock(context.Background(), db, fsys, "migrations"), "database is dirty at version 2")
+
+		require.NoError(t, database.ForceMigrationFS(db, fsys, "migrations", 1))
+		status, err = database.ReadMigrationStatusFS(db, fsys, "migrations")
+		require.NoError(t, err)
+		require.False(t, status.Dirty)
+		require.Equal(t, []string{"2_fail"}, status.Pending)
+	})
 }
@@ -5,64 +5,206 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"slices"
 	"sync"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	grpcsrv "github.com/tscolari/servicetools/server/grpc"
 )
 
 // NewWithGRPC returns a WithGRPC object set to listen at the given address.
+// Besides TCP addresses, it can be a unix socket ("unix:///path.sock"), an inherited
+// file descriptor ("fd://3") or a socket passed by systemd ("systemd://<name>").
 func NewWithGRPC(address string, options ...grpc.ServerOption) *WithGRPC {
 	return &WithGRPC{
-		address:     address,
-		mutex:       new(sync.Mutex),
-		startedChan: make(chan struct{}),
-		options:     options,
+		address:   address,
+		lifecycle: newLifecycle(true),
+		mutex:     new(sync.Mutex),
+		recovery:  grpcsrv.NewRecovery(),
+		options:   options,
 	}
 }
 
-// GRPCRegisterFunc is used as arguments to the Start method.
+// GRPCRegisterFunc is used as arguments to the Register method.
 // It exposes the internal gRPC server and allow gRPC services to register to it.
 type GRPCRegisterFunc func(grpc.ServiceRegistrar)
 
 // WithGRPC defines the gRPC server capability.
+// Every RPC is traced (see the tracing package), and the handlers get the span, the
+// request ID (see the requestid package) and a logger annotated with them, and the
+// RPC method, from their context.
+// Requests that fail their own validation are rejected before reaching the handlers,
+// and panics in the handlers are recovered and returned as codes.Internal errors
+// (see ValidationInterceptor and Recovery in the server/grpc package).
+// It can be restarted after being stopped.
 type WithGRPC struct {
-	address     string
-	started     bool
-	startedChan chan struct{}
+	*lifecycle
 
-	options []grpc.ServerOption
-	mutex   *sync.Mutex
-	server  *grpc.Server
+	address string
+	given   *givenListener
+
+	options       []grpc.ServerOption
+	tls           *TLSCertificates
+	registerFuncs []GRPCRegisterFunc
+	recovery      *grpcsrv.Recovery
+	mutex         *sync.Mutex
+	server        *grpc.Server
+
+	// shared is set when the server is served by the listener of a WithHTTP.
+	shared *sharedGRPC
+}
+
+var _ Component = &WithGRPC{}
+
+// Name returns the name of the component.
+func (s *WithGRPC) Name() string {
+	return "grpc"
+}
+
+// Register adds registerFuncs to be executed when the server starts.
+// This allows services to be registered ahead of time, so that Start can
+// be called by someone else (e.g. the cmd package).
+func (s *WithGRPC) Register(registerFuncs ...GRPCRegisterFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.registerFuncs = append(s.registerFuncs, registerFuncs...)
+}
+
+// AddOptions adds options to the ones given to NewWithGRPC, e.g. to install more
+// interceptors. Interceptors added this way run before the built-in ones.
+// It must be called before Start.
+func (s *WithGRPC) AddOptions(options ...grpc.ServerOption) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.options = append(s.options, options...)
+}
+
+// UseListener makes the server serve on the given listener, e.g. one created by a
+// test or inherited from a previous process, instead of listening to its address.
+// The listener is closed when the server stops, so it can't be restarted afterwards.
+// It must be called before Start.
+func (s *WithGRPC) UseListener(listener net.Listener) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.given = &givenListener{listener: listener}
+}
+
+// UseTLS makes the server accept only TLS connections, using the given certificates.
+// It must be called before Start.
+func (s *WithGRPC) UseTLS(certificates *TLSCertificates) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.tls = certificates
+}
+
+// TLS returns the certificates given to UseTLS, or nil if the server doesn't use TLS.
+func (s *WithGRPC) TLS() *TLSCertificates {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.tls
+}
+
+// Recovery returns the interceptors that recover from panics in the handlers.
+// Its metrics must be registered (e.g. with WithMetrics.Registerer) to be exposed.
+func (s *WithGRPC) Recovery() *grpcsrv.Recovery {
+	return s.recovery
+}
+
+// Services returns the services, and their methods, that the registered
+// registerFuncs register, without starting the server.
+// The result has the same format as grpc.Server.GetServiceInfo.
+func (s *WithGRPC) Services() map[string]grpc.ServiceInfo {
+	s.mutex.Lock()
+	registerFuncs := s.registerFuncs
+	s.mutex.Unlock()
+
+	registrar := serviceInfoRegistrar{}
+	for _, registerFunc := range registerFuncs {
+		registerFunc(registrar)
+	}
+
+	return registrar
+}
+
+// serviceInfoRegistrar is a grpc.ServiceRegistrar that only records the services registered to it.
+type serviceInfoRegistrar map[string]grpc.ServiceInfo
+
+func (r serviceInfoRegistrar) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	info := grpc.ServiceInfo{Metadata: desc.Metadata}
+
+	for _, method := range desc.Methods {
+		info.Methods = append(info.Methods, grpc.MethodInfo{Name: method.MethodName})
+	}
+
+	for _, stream := range desc.Streams {
+		info.Methods = append(info.Methods, grpc.MethodInfo{
+			Name:           stream.StreamName,
+			IsClientStream: stream.ClientStreams,
+			IsServerStream: stream.ServerStreams,
+		})
+	}
+
+	r[desc.ServiceName] = info
 }
 
 // Start will bind the internal gRPC server to the address and execute all
-// given registerFuncs.
+// registered registerFuncs.
 // This will block until the server is stopped (using Stop()).
-func (s *WithGRPC) Start(ctx context.Context, logger *slog.Logger, registerFuncs ...GRPCRegisterFunc) error {
-	if s.started {
-		return nil
+// If the server shares the listener of a WithHTTP (see ServeWithHTTP), it doesn't
+// listen to its own address, and RPCs are only served while it's running.
+func (s *WithGRPC) Start(ctx context.Context, logger *slog.Logger) error {
+	if err := s.lifecycle.start(); err != nil {
+		return err
 	}
 
 	s.mutex.Lock()
+	shared := s.shared
+	s.mutex.Unlock()
 
-	listener, err := net.Listen("tcp", s.address)
-	if err != nil {
-		s.mutex.Unlock()
-		return fmt.Errorf("failed to create listener: %w", err)
+	var listener net.Listener
+	if shared == nil {
+		var err error
+		listener, err = s.listen()
+		if err != nil {
+			return s.lifecycle.finish(fmt.Errorf("failed to create listener: %w", err))
+		}
 	}
 
-	s.server = grpc.NewServer(
-		append(s.options,
-			grpc.ChainUnaryInterceptor(
-				grpcsrv.LoggerInterceptor(logger),
-				grpcsrv.LoggerAnnotationInterceptor,
-			),
-		)...,
+	s.mutex.Lock()
+	options := append(slices.Clip(s.options),
+		grpc.ChainUnaryInterceptor(
+			grpcsrv.LoggerInterceptor(logger),
+			grpcsrv.TracingInterceptor,
+			grpcsrv.RequestIDInterceptor,
+			grpcsrv.LoggerAnnotationInterceptor,
+			s.recovery.UnaryInterceptor,
+			grpcsrv.ValidationInterceptor,
+		),
+		grpc.ChainStreamInterceptor(
+			grpcsrv.StreamLoggerInterceptor(logger),
+			grpcsrv.StreamTracingInterceptor,
+			grpcsrv.StreamRequestIDInterceptor,
+			grpcsrv.StreamLoggerAnnotationInterceptor,
+			s.recovery.StreamInterceptor,
+			grpcsrv.StreamValidationInterceptor,
+		),
 	)
 
-	for _, registerFunc := range registerFuncs {
+	// With a shared listener, TLS is terminated by the HTTP server.
+	if s.tls != nil && shared == nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(s.tls.Config())))
+	}
+
+	s.server = grpc.NewServer(options...)
+
+	for _, registerFunc := range s.registerFuncs {
 		registerFunc(s.server)
 	}
 
@@ -70,32 +212,89 @@ func (s *WithGRPC) Start(ctx context.Context, logger *slog.Logger, registerFuncs
 		logger.Info("service registered", "service_name", serviceName)
 	}
 
-	s.address = listener.Addr().String()
-	s.started = true
-	logger.Info("starting GRPC Server", "address", s.address)
+	server := s.server
+	if shared == nil {
+		s.address = listenerAddress(s.address, listener)
+		logger.Info("starting GRPC Server", "address", s.address, "tls", s.tls != nil)
+	}
 	s.mutex.Unlock()
 
-	close(s.startedChan)
+	if shared != nil {
+		logger.Info("starting GRPC Server on the HTTP listener")
+		stopped := shared.serve(server)
+		s.lifecycle.running()
 
-	if err = s.server.Serve(listener); err != nil {
-		return fmt.Errorf("grpc server returned an error: %w", err)
+		<-stopped
+		return s.lifecycle.finish(nil)
 	}
 
-	return nil
-}
+	s.lifecycle.running()
 
-// StartedChan can be used by a caller to block until the server has started.
-// Once the server has started, the channel will be closed and unblocked.
-func (s *WithGRPC) StartedChan() <-chan struct{} {
-	return s.startedChan
+	err := server.Serve(listener)
+	if err != nil {
+		err = fmt.Errorf("grpc server returned an error: %w", err)
+	}
+
+	return s.lifecycle.finish(err)
 }
 
-// Stop will gracefully stop the internal gRPC Server.
-func (s *WithGRPC) Stop(ctx context.Context) {
+func (s *WithGRPC) listen() (net.Listener, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	s.server.GracefulStop()
+	if s.given != nil {
+		return s.given.take()
+	}
+
+	return listen(s.address)
+}
+
+// Stop will gracefully stop the internal gRPC Server, and wait for Start to return.
+// If the context is done before all pending RPCs finish, the server is stopped
+// forcefully, canceling them, and the context error is returned.
+// It returns ErrNotStarted if the server was never started.
+func (s *WithGRPC) Stop(ctx context.Context, logger *slog.Logger) error {
+	shouldStop, err := s.lifecycle.stop(ctx)
+	if !shouldStop {
+		return err
+	}
+
+	s.mutex.Lock()
+	server := s.server
+	shared := s.shared
+	s.mutex.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		if shared != nil {
+			shared.drain()
+		} else {
+			server.GracefulStop()
+		}
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		if shared != nil {
+			shared.stop()
+		}
+
+		return s.lifecycle.wait(ctx)
+
+	case <-ctx.Done():
+		logger.Warn("graceful stop timed out, forcing the GRPC Server to stop")
+		server.Stop()
+		<-stopped
+
+		if shared != nil {
+			shared.stop()
+		}
+
+		// Start returns right after the server is stopped.
+		_ = s.lifecycle.wait(context.WithoutCancel(ctx))
+		return fmt.Errorf("grpc server was forced to stop: %w", ctx.Err())
+	}
 }
 
 // ConfigureGRPC is the hook used by the cmd package to inject the
@@ -10,6 +10,15 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tscolari/servicetools/testhelpers"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/tscolari/servicetools/requestid"
+	grpcsrv "github.com/tscolari/servicetools/server/grpc"
 )
 
 func Test_WithGRPC(t *testing.T) {
@@ -18,15 +27,15 @@ func Test_WithGRPC(t *testing.T) {
 		listener2Ok := false
 
 		withGRPC := NewWithGRPC("localhost:0", grpc.ConnectionTimeout(100*time.Millisecond))
-		defer withGRPC.Stop(context.Background())
+		defer withGRPC.Stop(context.Background(), slog.Default())
 
 		go func() {
-			<-withGRPC.StartedChan()
+			<-withGRPC.Ready()
 			listener1Ok = true
 		}()
 
 		go func() {
-			<-withGRPC.StartedChan()
+			<-withGRPC.Ready()
 			listener2Ok = true
 		}()
 
@@ -54,10 +63,10 @@ func Test_WithGRPC(t *testing.T) {
 		go func() {
 			require.NoError(t, withGRPC.Start(context.Background(), slog.Default()))
 		}()
-		defer withGRPC.Stop(context.Background())
+		defer withGRPC.Stop(context.Background(), slog.Default())
 
 		select {
-		case <-withGRPC.StartedChan():
+		case <-withGRPC.Ready():
 		case <-time.After(100 * time.Millisecond):
 			require.Fail(t, "timed out waiting for server to start")
 		}
@@ -71,11 +80,133 @@ func Test_WithGRPC(t *testing.T) {
 		})
 
 		t.Run("closing the server", func(t *testing.T) {
-			withGRPC.Stop(context.Background())
+			withGRPC.Stop(context.Background(), slog.Default())
 
 			_, err := net.DialTimeout("tcp", withGRPC.address, 50*time.Millisecond)
 			require.Error(t, err)
 			require.Contains(t, err.Error(), "connection refused")
 		})
 	})
+
+	t.Run("lifecycle", func(t *testing.T) {
+		withGRPC := NewWithGRPC("localhost:0")
+		require.Equal(t, StateNew, withGRPC.State())
+		require.ErrorIs(t, withGRPC.Stop(context.Background(), slog.Default()), ErrNotStarted)
+
+		for i := 0; i < 2; i++ {
+			errChan := make(chan error)
+			go func() {
+				errChan <- withGRPC.Start(context.Background(), slog.Default())
+			}()
+
+			// Ready stays closed after the first run, until it's restarted.
+			require.Eventually(t, func() bool {
+				return withGRPC.State() == StateRunning
+			}, 100*time.Millisecond, time.Millisecond, "timed out waiting for server to start")
+
+			select {
+			case <-withGRPC.Ready():
+			default:
+				require.Fail(t, "ready channel should be closed")
+			}
+
+			require.ErrorIs(t, withGRPC.Start(context.Background(), slog.Default()), ErrAlreadyStarted)
+
+			require.NoError(t, withGRPC.Stop(context.Background(), slog.Default()))
+			require.NoError(t, <-errChan)
+			require.Equal(t, StateStopped, withGRPC.State())
+		}
+	})
+
+	t.Run("failing to start", func(t *testing.T) {
+		withGRPC := NewWithGRPC("invalid-address")
+		require.Error(t, withGRPC.Start(context.Background(), slog.Default()))
+		require.Equal(t, StateFailed, withGRPC.State())
+		require.NoError(t, withGRPC.Stop(context.Background(), slog.Default()))
+	})
+
+	t.Run("describing services without starting", func(t *testing.T) {
+		withGRPC := NewWithGRPC("localhost:0")
+		withGRPC.Register(func(r grpc.ServiceRegistrar) {
+			grpc_health_v1.RegisterHealthServer(r, health.NewServer())
+		})
+
+		services := withGRPC.Services()
+		require.Len(t, services, 1)
+		require.ElementsMatch(t, []grpc.MethodInfo{
+			{Name: "Check"},
+			{Name: "Watch", IsServerStream: true},
+		}, services["grpc.health.v1.Health"].Methods)
+		require.Equal(t, StateNew, withGRPC.State())
+	})
+
+	t.Run("recovering from panics", func(t *testing.T) {
+		withGRPC := NewWithGRPC("localhost:0")
+		withGRPC.Register(func(r grpc.ServiceRegistrar) {
+			grpc_health_v1.RegisterHealthServer(r, panickingHealthServer{})
+		})
+
+		startTestComponent(t, withGRPC)
+
+		conn, err := grpc.Dial(withGRPC.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		client := grpc_health_v1.NewHealthClient(conn)
+		for i := 0; i < 2; i++ {
+			_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+			require.Equal(t, codes.Internal, status.Code(err))
+		}
+
+		require.Equal(t, StateRunning, withGRPC.State())
+	})
+
+	t.Run("propagating request IDs", func(t *testing.T) {
+		var received string
+		withGRPC := NewWithGRPC("localhost:0")
+		withGRPC.Register(func(r grpc.ServiceRegistrar) {
+			grpc_health_v1.RegisterHealthServer(r, requestIDHealthServer{received: &received})
+		})
+
+		startTestComponent(t, withGRPC)
+
+		conn, err := grpc.Dial(withGRPC.address,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithChainUnaryInterceptor(grpcsrv.RequestIDClientInterceptor),
+		)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		client := grpc_health_v1.NewHealthClient(conn)
+
+		var header metadata.MD
+		ctx := requestid.ToContext(context.Background(), "abc")
+		_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Equal(t, "abc", received)
+		require.Equal(t, []string{"abc"}, header.Get(requestid.Header))
+
+		_, err = client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Len(t, received, 21)
+		require.Equal(t, []string{received}, header.Get(requestid.Header))
+	})
+}
+
+type panickingHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (panickingHealthServer) Check(context.Context, *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	panic("check failed")
+}
+
+type requestIDHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	received *string
+}
+
+func (s requestIDHealthServer) Check(ctx context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	*s.received, _ = requestid.FromContext(ctx)
+	return &grpc_health_v1.HealthCheckResponse{}, nil
 }
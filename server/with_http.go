@@ -2,102 +2,227 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"sync"
+
+	"github.com/tscolari/servicetools/logging"
+	"github.com/tscolari/servicetools/requestid"
+	"github.com/tscolari/servicetools/tracing"
 )
 
 // NewWithHTTP returns a WithHTTP object configured with the given address.
+// Besides TCP addresses, it can be a unix socket ("unix:///path.sock"), an inherited
+// file descriptor ("fd://3") or a socket passed by systemd ("systemd://<name>").
 func NewWithHTTP(address string) *WithHTTP {
 	return &WithHTTP{
-		address:     address,
-		mutex:       new(sync.Mutex),
-		startedChan: make(chan struct{}),
+		address:   address,
+		lifecycle: newLifecycle(true),
+		mutex:     new(sync.Mutex),
 	}
 }
 
 // WithHTTP adds an HTTP server capability to another struct.
-// It allows endpoints to be "registed" during Start, and provides
+// It allows endpoints to be "registed" before Start, and provides
 // a Stop method for shutting down the server.
-// Once WithHTTP is ready to listen, it will send a signal to the
-// channel returned by the StartedChan method.
+// Once WithHTTP is ready to listen, it will close the
+// channel returned by the Ready method.
+// It can be restarted after being stopped.
 type WithHTTP struct {
-	address     string
-	started     bool
-	startedChan chan struct{}
+	*lifecycle
+
+	address string
+	given   *givenListener
 
-	mutex  *sync.Mutex
-	server *http.Server
-	mux    *http.ServeMux
+	tls           *TLSCertificates
+	grpc          *sharedGRPC
+	registerFuncs []HTTPRegisterFunc
+	mutex         *sync.Mutex
+	server        *http.Server
+	mux           *http.ServeMux
 }
 
-// HTTPRegisterFunc defines the functions that can be passed to Start
+var _ Component = &WithHTTP{}
+
+// HTTPRegisterFunc defines the functions that can be passed to Register
 // in order to register new endpoints in the internal mux.
 type HTTPRegisterFunc func(handle func(path string, handler func(http.ResponseWriter, *http.Request)))
 
+// Name returns the name of the component.
+func (s *WithHTTP) Name() string {
+	return "http"
+}
+
+// Register adds registerFuncs to be executed when the server starts.
+// This allows endpoints to be registered ahead of time, so that Start can
+// be called by someone else (e.g. the cmd package).
+func (s *WithHTTP) Register(registerFuncs ...HTTPRegisterFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.registerFuncs = append(s.registerFuncs, registerFuncs...)
+}
+
+// UseListener makes the server serve on the given listener, e.g. one created by a
+// test or inherited from a previous process, instead of listening to its address.
+// The listener is closed when the server stops, so it can't be restarted afterwards.
+// It must be called before Start.
+func (s *WithHTTP) UseListener(listener net.Listener) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.given = &givenListener{listener: listener}
+}
+
+// UseTLS makes the server accept only TLS connections, using the given certificates.
+// With mutual TLS, the identity of the clients is available to the handlers
+// with PeerIdentityFromContext.
+// It must be called before Start.
+func (s *WithHTTP) UseTLS(certificates *TLSCertificates) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.tls = certificates
+}
+
+// TLS returns the certificates given to UseTLS, or nil if the server doesn't use TLS.
+func (s *WithHTTP) TLS() *TLSCertificates {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.tls
+}
+
+// Patterns returns the patterns that the registered registerFuncs handle,
+// in the order that they are registered, without starting the server.
+func (s *WithHTTP) Patterns() []string {
+	s.mutex.Lock()
+	registerFuncs := s.registerFuncs
+	s.mutex.Unlock()
+
+	var patterns []string
+	for _, registerFunc := range registerFuncs {
+		registerFunc(func(pattern string, _ func(http.ResponseWriter, *http.Request)) {
+			patterns = append(patterns, pattern)
+		})
+	}
+
+	return patterns
+}
+
 // ConfigureHTTP is the hook used by the cmd package to inject the
 // WithHTTP object in the host struct. This must be implemented by the host struct.
 func (s *WithHTTP) ConfigureHTTP(*WithHTTP) {
 	panic("ConfigureHTTP must be implemented")
 }
 
-// Start will register all given registerFuncs to the internal mux, bind
+// Start will register all registered registerFuncs to the internal mux, bind
 // the internal HTTP server to the listening address and block until the server shuts down.
-// To wait for the server to start, the channel in the StartedChan() method can be used.
-func (s *WithHTTP) Start(ctx context.Context, logger *slog.Logger, registerFuncs ...HTTPRegisterFunc) error {
-	s.mutex.Lock()
-
-	if s.started {
-		s.mutex.Unlock()
-		return fmt.Errorf("the server was already started")
+// Every request is traced (see the tracing package), and the handlers get the span,
+// the request ID (see the requestid package) and a logger annotated with them from
+// the context of the requests.
+// To wait for the server to start, the channel in the Ready() method can be used.
+func (s *WithHTTP) Start(ctx context.Context, logger *slog.Logger) error {
+	if err := s.lifecycle.start(); err != nil {
+		return err
 	}
 
-	listener, err := net.Listen("tcp", s.address)
+	listener, err := s.listen()
 	if err != nil {
-		s.mutex.Unlock()
-		return fmt.Errorf("failed to create listener: %w", err)
+		return s.lifecycle.finish(fmt.Errorf("failed to create listener: %w", err))
 	}
 
-	s.address = listener.Addr().String()
+	s.mutex.Lock()
+	s.address = listenerAddress(s.address, listener)
 	s.mux = http.NewServeMux()
-	for _, registerFunc := range registerFuncs {
+	for _, registerFunc := range s.registerFuncs {
 		// inject "interceptors" here wrapping mix.Handle
 		registerFunc(s.mux.HandleFunc)
 	}
 
 	s.server = &http.Server{
-		Handler: s.mux,
+		Handler: requestid.Handler(tracing.Handler(s.mux)),
+		BaseContext: func(net.Listener) context.Context {
+			return logging.ToContext(context.Background(), logger)
+		},
 	}
 
-	s.started = true
+	if s.tls != nil {
+		s.server.Handler = withPeerIdentity(s.server.Handler)
+		s.server.TLSConfig = s.tls.Config()
+	}
 
-	logger.Info("starting HTTP Server", "address", listener.Addr().String())
+	if s.grpc != nil {
+		s.server.Handler, err = s.grpc.sharedHandler(s.server, s.server.Handler)
+		if err != nil {
+			s.mutex.Unlock()
+			_ = listener.Close()
+			return s.lifecycle.finish(fmt.Errorf("failed to configure HTTP/2: %w", err))
+		}
+	}
 
+	server := s.server
+	useTLS := s.tls != nil
+	sharesGRPC := s.grpc != nil
 	s.mutex.Unlock()
-	close(s.startedChan)
 
-	if err := s.server.Serve(listener); err != nil {
-		if err != http.ErrServerClosed {
-			return fmt.Errorf("http server returned an error: %w", err)
-		}
+	logger.Info("starting HTTP Server", "address", listener.Addr().String(), "tls", useTLS, "grpc", sharesGRPC)
+	s.lifecycle.running()
+
+	if err := serveHTTP(server, listener, useTLS); err != nil && err != http.ErrServerClosed {
+		return s.lifecycle.finish(fmt.Errorf("http server returned an error: %w", err))
 	}
 
-	return nil
+	return s.lifecycle.finish(nil)
+}
+
+func (s *WithHTTP) listen() (net.Listener, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.given != nil {
+		return s.given.take()
+	}
+
+	return listen(s.address)
 }
 
 // Stop will gracefully stop the internal HTTP server.
 // This will cause the Start function to return.
+// If the context is done before all pending requests finish, the server is
+// closed forcefully, dropping them.
+// It returns ErrNotStarted if the server was never started.
 func (s *WithHTTP) Stop(ctx context.Context, logger *slog.Logger) error {
+	shouldStop, err := s.lifecycle.stop(ctx)
+	if !shouldStop {
+		return err
+	}
+
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	server := s.server
+	s.mutex.Unlock()
 
-	return s.server.Shutdown(ctx)
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warn("graceful shutdown timed out, closing the HTTP Server", "error", err)
+		closeErr := server.Close()
+
+		// Start returns right after the server is closed.
+		_ = s.lifecycle.wait(context.WithoutCancel(ctx))
+		return errors.Join(fmt.Errorf("http server was forced to stop: %w", err), closeErr)
+	}
+
+	return s.lifecycle.wait(ctx)
 }
 
-// StartedChan returns a channel that can be used to observe if
-// the server has started or not.
-func (s *WithHTTP) StartedChan() <-chan struct{} {
-	return s.startedChan
+// serveHTTP serves the server in the listener, using TLS if requested.
+func serveHTTP(server *http.Server, listener net.Listener, useTLS bool) error {
+	if useTLS {
+		// The certificates are given by the TLS configuration.
+		return server.ServeTLS(listener, "", "")
+	}
+
+	return server.Serve(listener)
 }
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/tscolari/servicetools/requestid"
 	"github.com/tscolari/servicetools/testhelpers"
 )
 
@@ -21,12 +22,12 @@ func Test_WithHTTP(t *testing.T) {
 		withHTTP := NewWithHTTP("localhost:0")
 
 		go func() {
-			<-withHTTP.StartedChan()
+			<-withHTTP.Ready()
 			listener1Ok = true
 		}()
 
 		go func() {
-			<-withHTTP.StartedChan()
+			<-withHTTP.Ready()
 			listener2Ok = true
 		}()
 
@@ -56,7 +57,7 @@ func Test_WithHTTP(t *testing.T) {
 		}()
 
 		select {
-		case <-withHTTP.StartedChan():
+		case <-withHTTP.Ready():
 		case <-time.After(100 * time.Millisecond):
 			require.Fail(t, "timed out waiting for server to start")
 		}
@@ -99,15 +100,17 @@ func Test_WithHTTP(t *testing.T) {
 			})
 		}
 
+		withHTTP.Register(service1, service2)
+
 		go func() {
-			require.NoError(t, withHTTP.Start(context.Background(), slog.Default(), service1, service2))
+			require.NoError(t, withHTTP.Start(context.Background(), slog.Default()))
 		}()
 		defer func() {
 			require.NoError(t, withHTTP.Stop(context.Background(), slog.Default()))
 		}()
 
 		select {
-		case <-withHTTP.StartedChan():
+		case <-withHTTP.Ready():
 		case <-time.After(100 * time.Millisecond):
 			require.Fail(t, "timed out waiting for server to start")
 		}
@@ -124,4 +127,110 @@ func Test_WithHTTP(t *testing.T) {
 		require.NoError(t, err)
 		require.True(t, foobarCalled, "foobar should have been called")
 	})
+
+	t.Run("lifecycle", func(t *testing.T) {
+		withHTTP := NewWithHTTP("localhost:0")
+		require.Equal(t, StateNew, withHTTP.State())
+		require.ErrorIs(t, withHTTP.Stop(context.Background(), slog.Default()), ErrNotStarted)
+
+		for i := 0; i < 2; i++ {
+			errChan := make(chan error)
+			go func() {
+				errChan <- withHTTP.Start(context.Background(), slog.Default())
+			}()
+
+			// Ready stays closed after the first run, until it's restarted.
+			require.Eventually(t, func() bool {
+				return withHTTP.State() == StateRunning
+			}, 100*time.Millisecond, time.Millisecond, "timed out waiting for server to start")
+
+			select {
+			case <-withHTTP.Ready():
+			default:
+				require.Fail(t, "ready channel should be closed")
+			}
+
+			require.ErrorIs(t, withHTTP.Start(context.Background(), slog.Default()), ErrAlreadyStarted)
+
+			require.NoError(t, withHTTP.Stop(context.Background(), slog.Default()))
+			require.NoError(t, <-errChan)
+			require.Equal(t, StateStopped, withHTTP.State())
+		}
+	})
+
+	t.Run("stopping with pending requests", func(t *testing.T) {
+		withHTTP := NewWithHTTP("localhost:0")
+
+		requestStarted := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+
+		withHTTP.Register(func(handle func(path string, handler func(http.ResponseWriter, *http.Request))) {
+			handle("/hang", func(w http.ResponseWriter, r *http.Request) {
+				close(requestStarted)
+				<-release
+			})
+		})
+
+		errChan := make(chan error)
+		go func() {
+			errChan <- withHTTP.Start(context.Background(), slog.Default())
+		}()
+
+		<-withHTTP.Ready()
+
+		go func() {
+			_, _ = http.Get(fmt.Sprintf("http://%s/hang", withHTTP.address))
+		}()
+		<-requestStarted
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := withHTTP.Stop(ctx, slog.Default())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorContains(t, err, "forced to stop")
+
+		select {
+		case err := <-errChan:
+			require.NoError(t, err)
+		case <-time.After(100 * time.Millisecond):
+			require.Fail(t, "timed out waiting for Start to return")
+		}
+	})
+
+	t.Run("listing patterns without starting", func(t *testing.T) {
+		withHTTP := NewWithHTTP("localhost:0")
+		withHTTP.Register(func(handle func(path string, handler func(http.ResponseWriter, *http.Request))) {
+			handle("/hello", func(http.ResponseWriter, *http.Request) {})
+			handle("GET /bye/{name}", func(http.ResponseWriter, *http.Request) {})
+		})
+
+		require.Equal(t, []string{"/hello", "GET /bye/{name}"}, withHTTP.Patterns())
+		require.Equal(t, StateNew, withHTTP.State())
+	})
+
+	t.Run("request IDs", func(t *testing.T) {
+		withHTTP := NewWithHTTP("localhost:0")
+
+		var received string
+		withHTTP.Register(func(handle func(path string, handler func(http.ResponseWriter, *http.Request))) {
+			handle("/id", func(w http.ResponseWriter, r *http.Request) {
+				received, _ = requestid.FromContext(r.Context())
+			})
+		})
+
+		startTestComponent(t, withHTTP)
+
+		r, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/id", withHTTP.address), nil)
+		require.NoError(t, err)
+		r.Header.Set(requestid.Header, "abc")
+
+		resp, err := http.DefaultClient.Do(r)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		require.Equal(t, "abc", received)
+		require.Equal(t, "abc", resp.Header.Get(requestid.Header))
+	})
 }
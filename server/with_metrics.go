@@ -2,66 +2,191 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/heptiolabs/healthcheck"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/tscolari/servicetools/buildinfo"
 )
 
 // NewWithMetrics returns a WithMetrics object configured with address.
+// Besides TCP addresses, it can be a unix socket ("unix:///path.sock"), an inherited
+// file descriptor ("fd://3") or a socket passed by systemd ("systemd://<name>").
 func NewWithMetrics(address string) *WithMetrics {
 	return &WithMetrics{
-		address: address,
+		address:       address,
+		healthHandler: healthcheck.NewHandler(),
+		handlers:      map[string]http.Handler{},
+		lifecycle:     newLifecycle(true),
+		registry:      newBuildInfoRegistry(buildinfo.Read()),
 	}
 }
 
+// newBuildInfoRegistry returns a registry with the `build_info` gauge, always set to 1,
+// labeled with the given build information.
+func newBuildInfoRegistry(info buildinfo.Info) *prometheus.Registry {
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "build_info",
+		Help: "Build information of the running service, always 1.",
+		ConstLabels: prometheus.Labels{
+			"version":    info.Version,
+			"revision":   info.Revision,
+			"dirty":      strconv.FormatBool(info.Dirty),
+			"go_version": info.GoVersion,
+		},
+	})
+	buildInfo.Set(1)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(buildInfo)
+
+	return registry
+}
+
 // WithMetrics implements a simple HTTP server that responds to the `/metrics` endpoint
-// with exposed prometheus metrics.
+// with exposed prometheus metrics, along with a `build_info` gauge (see the buildinfo package).
+// It can be restarted after being stopped.
 type WithMetrics struct {
-	address string
+	*lifecycle
+
+	address       string
+	given         *givenListener
+	healthHandler healthcheck.Handler
+	handlers      map[string]http.Handler
+	tls           *TLSCertificates
+
+	// registry holds the metrics owned by this component, exposed along with
+	// the ones in the default registry.
+	registry *prometheus.Registry
 
 	listener net.Listener
 	server   *http.Server
 }
 
-// StartMetrics will start the HTTP metrics server and block
-// until the StopMetrics method is called.
-// This also takes an optional healthHandler, which must implement the healthcheck interface -
-// When provided, that will allow this server to respond to liveness and readiness probes.
-func (h *WithMetrics) StartMetrics(logger *slog.Logger, healthHandler healthcheck.Handler) error {
+var _ Component = &WithMetrics{}
+
+// Name returns the name of the component.
+func (h *WithMetrics) Name() string {
+	return "metrics"
+}
+
+// HealthHandler returns the health handler that responds to liveness and readiness probes.
+// Liveness and readiness checks can be added to it before the server starts.
+func (h *WithMetrics) HealthHandler() healthcheck.Handler {
+	return h.healthHandler
+}
+
+// UseListener makes the server serve on the given listener, e.g. one created by a
+// test or inherited from a previous process, instead of listening to its address.
+// The listener is closed when the server stops, so it can't be restarted afterwards.
+// It must be called before Start.
+func (h *WithMetrics) UseListener(listener net.Listener) {
+	h.given = &givenListener{listener: listener}
+}
+
+// UseTLS makes the server accept only TLS connections, using the given certificates.
+// Probes must then use HTTPS, and present a client certificate in case of mutual TLS.
+// It must be called before Start.
+func (h *WithMetrics) UseTLS(certificates *TLSCertificates) {
+	h.tls = certificates
+}
+
+// TLS returns the certificates given to UseTLS, or nil if the server doesn't use TLS.
+func (h *WithMetrics) TLS() *TLSCertificates {
+	return h.tls
+}
+
+// Registerer returns the registerer of the metrics owned by this component, which
+// are exposed along with the ones in the default registry.
+// Unlike the default registry, it's not shared with other WithMetrics objects.
+func (h *WithMetrics) Registerer() prometheus.Registerer {
+	return h.registry
+}
+
+// Handle mounts an extra handler in the metrics server, e.g. for admin endpoints.
+// It must be called before Start.
+func (h *WithMetrics) Handle(pattern string, handler http.Handler) {
+	h.handlers[pattern] = handler
+}
+
+// Start will start the HTTP metrics server and block
+// until the Stop method is called.
+// Besides the `/metrics` endpoint, the server will respond to liveness and readiness
+// probes using the handler returned by HealthHandler().
+func (h *WithMetrics) Start(ctx context.Context, logger *slog.Logger) error {
+	if err := h.lifecycle.start(); err != nil {
+		return err
+	}
 
-	lis, err := net.Listen("tcp", h.address)
+	lis, err := h.listen()
 	if err != nil {
-		return fmt.Errorf("failed to create listener: %w", err)
+		return h.lifecycle.finish(fmt.Errorf("failed to create listener: %w", err))
 	}
 
 	mux := http.NewServeMux()
-	if healthHandler != nil {
-		mux.Handle("/", healthHandler)
-	}
+	mux.Handle("/", h.healthHandler)
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, h.registry}, promhttp.HandlerOpts{}),
+	))
 
-	mux.Handle("/metrics", promhttp.Handler())
+	for pattern, handler := range h.handlers {
+		mux.Handle(pattern, handler)
+	}
 
 	h.server = &http.Server{Handler: mux}
 	h.listener = lis
 
-	logger.Info("starting Metrics Server", "address", h.listener.Addr().String())
+	if h.tls != nil {
+		h.server.Handler = withPeerIdentity(mux)
+		h.server.TLSConfig = h.tls.Config()
+	}
+
+	logger.Info("starting Metrics Server", "address", h.listener.Addr().String(), "tls", h.tls != nil)
+	h.lifecycle.running()
 
-	if err := h.server.Serve(h.listener); err != nil {
-		if err != http.ErrServerClosed {
-			return fmt.Errorf("metrics server returned an error: %w", err)
-		}
+	if err := serveHTTP(h.server, h.listener, h.tls != nil); err != nil && err != http.ErrServerClosed {
+		return h.lifecycle.finish(fmt.Errorf("metrics server returned an error: %w", err))
 	}
 
-	return nil
+	return h.lifecycle.finish(nil)
 }
 
-// StopMetrics will stop the Metrics server and cause Start() to unblock.
-func (h *WithMetrics) StopMetrics(ctx context.Context) error {
-	return h.server.Shutdown(ctx)
+func (h *WithMetrics) listen() (net.Listener, error) {
+	if h.given != nil {
+		return h.given.take()
+	}
+
+	return listen(h.address)
+}
+
+// Stop will stop the Metrics server and cause Start() to unblock.
+// If the context is done before all pending requests finish, the server is
+// closed forcefully.
+// It returns ErrNotStarted if the server was never started.
+func (h *WithMetrics) Stop(ctx context.Context, logger *slog.Logger) error {
+	shouldStop, err := h.lifecycle.stop(ctx)
+	if !shouldStop {
+		return err
+	}
+
+	if err := h.server.Shutdown(ctx); err != nil {
+		logger.Warn("graceful shutdown timed out, closing the Metrics Server", "error", err)
+		closeErr := h.server.Close()
+
+		// Start returns right after the server is closed.
+		_ = h.lifecycle.wait(context.WithoutCancel(ctx))
+		return errors.Join(fmt.Errorf("metrics server was forced to stop: %w", err), closeErr)
+	}
+
+	return h.lifecycle.wait(ctx)
 }
 
 // ConfigureMetrics is the hook used by the cmd package to inject the
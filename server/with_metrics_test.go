@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithMetrics(t *testing.T) {
+	t.Run("exposes build info and registered metrics", func(t *testing.T) {
+		withMetrics := NewWithMetrics("localhost:0")
+
+		counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "Test counter."})
+		require.NoError(t, withMetrics.Registerer().Register(counter))
+		counter.Add(3)
+
+		go func() {
+			require.NoError(t, withMetrics.Start(context.Background(), slog.Default()))
+		}()
+
+		select {
+		case <-withMetrics.Ready():
+		case <-time.After(100 * time.Millisecond):
+			require.Fail(t, "timed out waiting for server to start")
+		}
+
+		defer func() {
+			require.NoError(t, withMetrics.Stop(context.Background(), slog.Default()))
+		}()
+
+		resp, err := http.Get("http://" + withMetrics.listener.Addr().String() + "/metrics")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Regexp(t, `build_info\{dirty="(true|false)",go_version="go[^"]+",revision="[^"]*",version="[^"]*"\} 1`, string(body))
+
+		require.Contains(t, string(body), "test_total 3")
+
+		// Metrics from the default registry are still exposed.
+		require.Contains(t, string(body), "go_goroutines")
+	})
+}
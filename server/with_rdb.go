@@ -4,19 +4,27 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"sync"
 
 	"github.com/tscolari/servicetools/database"
 )
 
 // NewWithRDB returns a WithRDB object configured with the given config.
-func NewWithRDB(config *database.Config) (*WithRDB, error) {
-	db, err := openDB(config)
+// Connection attempts are retried according to the config (see database.Open),
+// and logged using the given logger.
+func NewWithRDB(ctx context.Context, logger *slog.Logger, config *database.Config) (*WithRDB, error) {
+	db, err := database.Open(ctx, logger, config)
 	if err != nil {
 		return nil, fmt.Errorf("database initialization failed: %w", err)
 	}
 
 	return &WithRDB{
-		BaseRDB: db,
+		config:    *config,
+		mutex:     new(sync.RWMutex),
+		BaseRDB:   db,
+		lifecycle: newLifecycle(false),
+		stopChan:  make(chan struct{}),
 	}, nil
 }
 
@@ -25,6 +33,107 @@ func NewWithRDB(config *database.Config) (*WithRDB, error) {
 // a second database access (on top of the WithDB) that is meant for read-only operations.
 type WithRDB struct {
 	BaseRDB *sql.DB
+
+	*lifecycle
+	stopChan chan struct{}
+
+	// mutex guards the swapping of BaseRDB in Reconnect.
+	mutex  *sync.RWMutex
+	config database.Config
+}
+
+var _ Component = &WithRDB{}
+
+// Name returns the name of the component.
+func (s *WithRDB) Name() string {
+	return "reader-database"
+}
+
+// Start marks the reader database as ready and blocks until it is stopped.
+// The connection is opened when the object is created, so there's
+// nothing else to be started.
+// Once stopped, the connection is closed and it can't be restarted.
+func (s *WithRDB) Start(ctx context.Context, logger *slog.Logger) error {
+	if err := s.lifecycle.start(); err != nil {
+		return err
+	}
+
+	s.lifecycle.running()
+
+	select {
+	case <-ctx.Done():
+	case <-s.stopChan:
+	}
+
+	return s.lifecycle.finish(nil)
+}
+
+// Stop closes the underlying database connection, causing Start to return.
+// It returns ErrNotStarted if the component was never started, in which case
+// Close should be used to close the connection.
+func (s *WithRDB) Stop(ctx context.Context, logger *slog.Logger) error {
+	shouldStop, err := s.lifecycle.stop(ctx)
+	if !shouldStop {
+		return err
+	}
+
+	close(s.stopChan)
+	if err := s.lifecycle.wait(ctx); err != nil {
+		return err
+	}
+
+	return s.Close()
+}
+
+// Close closes the underlying database connection.
+func (s *WithRDB) Close() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.BaseRDB == nil {
+		return nil
+	}
+
+	return s.BaseRDB.Close()
+}
+
+// Reconnect opens a new connection pool using the given config, e.g. with rotated
+// credentials, and replaces the current one with it.
+// The previous pool is closed once its in-flight queries finish, so callers
+// must get the connection from RDB() on every use, instead of holding on to it.
+// Nothing is done if the config didn't change.
+func (s *WithRDB) Reconnect(ctx context.Context, logger *slog.Logger, config *database.Config) error {
+	s.mutex.RLock()
+	unchanged := s.config == *config
+	s.mutex.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	db, err := database.Open(ctx, logger, config)
+	if err != nil {
+		return fmt.Errorf("database reconnection failed: %w", err)
+	}
+
+	s.mutex.Lock()
+	previous := s.BaseRDB
+	s.BaseRDB = db
+	s.config = *config
+	s.mutex.Unlock()
+
+	logger.Info("database connection pool replaced", "component", s.Name())
+
+	if previous != nil {
+		// Close blocks until in-flight queries finish.
+		go func() {
+			if err := previous.Close(); err != nil {
+				logger.Error("failed to close previous database connection pool", "component", s.Name(), "error", err)
+			}
+		}()
+	}
+
+	return nil
 }
 
 // ConfigureReaderDatabase is the hook used by the cmd package to inject the
@@ -35,9 +144,8 @@ func (s *WithRDB) ConfigureReaderDatabase(*WithRDB) {
 
 // RDB returns an usable DB connection, meant for read-only operations.
 func (s *WithRDB) RDB(ctx context.Context) *sql.DB {
-	if s.BaseRDB == nil {
-		return nil
-	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
 	return s.BaseRDB
 }
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"log/slog"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -26,7 +27,7 @@ func Test_WithRDB(t *testing.T) {
 		SSLMode:  false,
 	}
 
-	withRDB, err := NewWithRDB(&config)
+	withRDB, err := NewWithRDB(context.Background(), slog.Default(), &config)
 	require.NoError(t, err)
 
 	testObj := &testWithRDB{}
@@ -49,7 +50,7 @@ func Test_WithRDB(t *testing.T) {
 			SSLMode:  false,
 		}
 
-		_, err := NewWithDB(&config)
+		_, err := NewWithDB(context.Background(), slog.Default(), &config)
 		require.Error(t, err)
 	})
 }
@@ -2,34 +2,61 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"reflect"
+	"runtime"
 	"sync"
+
+	"github.com/tscolari/servicetools/logging"
+	"github.com/tscolari/servicetools/tracing"
 )
 
 // NewWithWorker returns a new worker object.
 func NewWithWorker() *WithWorker {
 	return &WithWorker{
-		mutex:       new(sync.Mutex),
-		startedChan: make(chan error),
+		lifecycle: newLifecycle(true),
+		mutex:     new(sync.Mutex),
 	}
 }
 
+// ErrUnknownTask is returned by RunTask when no task has the given name.
+var ErrUnknownTask = errors.New("unknown task")
+
 // WorkerTaskFunc defines a function that starts a task.
 // Tasks will be started in parallel, and they should exit when
 // the context is canceled.
+// The context holds a span covering the whole run of the task, so tasks that loop
+// until they are stopped should start a span of their own (see tracing.Start) for
+// each unit of work, otherwise the work is only exported once the task returns.
 type WorkerTaskFunc func(ctx context.Context, logger *slog.Logger) error
 
 // WithWorker implements simple worker capabilities.
 // It can be started with a list of taks (WorkerTaskFunc), where each
 // will be spawn in a goroutine.
+// It can be restarted after being stopped.
 type WithWorker struct {
+	*lifecycle
+
 	cancelCtx func()
 
-	mutex       *sync.Mutex
-	started     bool
-	startedChan chan error
-	wg          *sync.WaitGroup
+	mutex *sync.Mutex
+	tasks []workerTask
+	wg    *sync.WaitGroup
+}
+
+// workerTask is a registered WorkerTaskFunc and its name.
+type workerTask struct {
+	name string
+	run  WorkerTaskFunc
+}
+
+var _ Component = &WithWorker{}
+
+// Name returns the name of the component.
+func (w *WithWorker) Name() string {
+	return "worker"
 }
 
 // ConfigureWorker is the hook used by the cmd package to inject the
@@ -38,54 +65,155 @@ func (w *WithWorker) ConfigureWorker(*WithWorker) {
 	panic("ConfigureWorker must be implemented")
 }
 
-// Start will start all the given tasks, and block until all them are finished.
-// Once all tasks are started/scheduled, the channel from StartedChan() will unblock.
-// Tasks are WorkerTaskFunc functions, and they should exit once the given context
-// is canceled.
-func (w *WithWorker) Start(ctx context.Context, logger *slog.Logger, tasks ...WorkerTaskFunc) error {
+// Register adds tasks to be started when the worker starts.
+// This allows tasks to be registered ahead of time, so that Start can
+// be called by someone else (e.g. the cmd package).
+// Tasks are named after their functions, use RegisterNamed to give them names.
+func (w *WithWorker) Register(tasks ...WorkerTaskFunc) {
 	w.mutex.Lock()
+	defer w.mutex.Unlock()
 
-	if w.started {
-		w.mutex.Unlock()
-		return fmt.Errorf("the worker was already started")
+	for _, task := range tasks {
+		name := runtime.FuncForPC(reflect.ValueOf(task).Pointer()).Name()
+		w.tasks = append(w.tasks, workerTask{name: name, run: task})
 	}
+}
 
-	w.started = true
-	w.wg = new(sync.WaitGroup)
+// RegisterNamed adds a task, with the given name, to be started when the worker starts.
+// The name allows the task to be run on its own with RunTask, e.g. by a cron job.
+// It panics if a task with the same name is already registered.
+func (w *WithWorker) RegisterNamed(name string, task WorkerTaskFunc) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, registered := range w.tasks {
+		if registered.name == name {
+			panic(fmt.Sprintf("worker task %q is already registered", name))
+		}
+	}
+
+	w.tasks = append(w.tasks, workerTask{name: name, run: task})
+}
+
+// TaskNames returns the names of the registered tasks, in the order that they are registered.
+func (w *WithWorker) TaskNames() []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	names := make([]string, len(w.tasks))
+	for i, task := range w.tasks {
+		names[i] = task.name
+	}
+
+	return names
+}
+
+// RunTask runs only the (first) task with the given name, blocking until it returns
+// or the given context is canceled, without starting the worker.
+// It returns ErrUnknownTask if no task has the given name.
+func (w *WithWorker) RunTask(ctx context.Context, logger *slog.Logger, name string) error {
+	w.mutex.Lock()
+	var task WorkerTaskFunc
+	for _, registered := range w.tasks {
+		if registered.name == name {
+			task = registered.run
+			break
+		}
+	}
+	w.mutex.Unlock()
+
+	if task == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownTask, name)
+	}
+
+	logger.Info("running worker task", "task", name)
+
+	if err := runTask(ctx, logger.With("task", name), name, task); err != nil {
+		return fmt.Errorf("worker task %q failed: %w", name, err)
+	}
+
+	return nil
+}
+
+// Start will start all the registered tasks, and block until all them are finished.
+// Once all tasks are started/scheduled, the channel from Ready() will unblock.
+// Tasks are WorkerTaskFunc functions, and they should exit once the given context
+// is canceled.
+// If any of the tasks fails, all the other tasks are canceled and the error is returned.
+func (w *WithWorker) Start(ctx context.Context, logger *slog.Logger) error {
+	if err := w.lifecycle.start(); err != nil {
+		return err
+	}
 
 	taskCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w.mutex.Lock()
 	w.cancelCtx = cancel
+	w.wg = new(sync.WaitGroup)
+	wg := w.wg
+	tasks := w.tasks
+	w.mutex.Unlock()
 
-	for _, task := range tasks {
-		w.wg.Add(1)
-		task := task
+	errs := make([]error, len(tasks))
+
+	for i, task := range tasks {
+		wg.Add(1)
+		i, task := i, task
 
 		go func() {
-			defer w.wg.Done()
-			if err := task(taskCtx, logger); err != nil {
-				w.startedChan <- err
+			defer wg.Done()
+			if err := runTask(taskCtx, logger, task.name, task.run); err != nil {
+				errs[i] = err
+				cancel()
 			}
 		}()
 	}
 
 	logger.Info("starting Worker server")
-	w.mutex.Unlock()
-	close(w.startedChan)
-	w.wg.Wait()
+	w.lifecycle.running()
+	wg.Wait()
 
-	return nil
+	if err := errors.Join(errs...); err != nil {
+		return w.lifecycle.finish(fmt.Errorf("worker task failed: %w", err))
+	}
+
+	return w.lifecycle.finish(nil)
+}
+
+// runTask runs the task in a span (see the tracing package), giving it a logger
+// annotated with the span, also available from the context.
+// The span ends when the task returns, see WorkerTaskFunc.
+func runTask(ctx context.Context, logger *slog.Logger, name string, task WorkerTaskFunc) error {
+	ctx, span := tracing.Start(logging.ToContext(ctx, logger), "worker "+name, tracing.KindInternal)
+	defer span.End()
+
+	span.SetAttribute("worker.task", name)
+
+	err := task(ctx, logging.FromContext(ctx))
+	span.SetError(err)
+
+	return err
 }
 
 // Stop will signal to all internal tasks to stop, by canceling their internal contexts.
-func (w *WithWorker) Stop(ctx context.Context, logger *slog.Logger) {
+// It blocks until all tasks have returned, or the given context is done.
+// It returns ErrNotStarted if the worker was never started.
+func (w *WithWorker) Stop(ctx context.Context, logger *slog.Logger) error {
+	shouldStop, err := w.lifecycle.stop(ctx)
+	if !shouldStop {
+		return err
+	}
+
 	w.mutex.Lock()
-	defer w.mutex.Unlock()
+	cancel := w.cancelCtx
+	w.mutex.Unlock()
 
-	w.cancelCtx()
-}
+	cancel()
+
+	if err := w.lifecycle.wait(ctx); err != nil {
+		return fmt.Errorf("timed out waiting for worker tasks to stop: %w", err)
+	}
 
-// StartedChan returns a channel that can be used to inspect if all the tasks have
-// been started.
-func (w *WithWorker) StartedChan() <-chan error {
-	return w.startedChan
+	return nil
 }
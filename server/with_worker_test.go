@@ -1,13 +1,16 @@
 package server
 
 import (
+	"bytes"
 	context "context"
+	"errors"
 	slog "log/slog"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/tscolari/servicetools/testhelpers"
+	"github.com/tscolari/servicetools/tracing"
 )
 
 func Test_WithWorker(t *testing.T) {
@@ -18,12 +21,12 @@ func Test_WithWorker(t *testing.T) {
 		withWorker := NewWithWorker()
 
 		go func() {
-			<-withWorker.StartedChan()
+			<-withWorker.Ready()
 			listener1Ok = true
 		}()
 
 		go func() {
-			<-withWorker.StartedChan()
+			<-withWorker.Ready()
 			listener2Ok = true
 		}()
 
@@ -74,12 +77,14 @@ func Test_WithWorker(t *testing.T) {
 			}
 		}
 
+		withWorker.Register(taskOne, taskTwo)
+
 		go func() {
-			require.NoError(t, withWorker.Start(context.Background(), slog.Default(), taskOne, taskTwo))
+			require.NoError(t, withWorker.Start(context.Background(), slog.Default()))
 		}()
 
 		select {
-		case <-withWorker.StartedChan():
+		case <-withWorker.Ready():
 		case <-time.After(100 * time.Millisecond):
 			require.Fail(t, "timed out waiting for worker to start")
 		}
@@ -92,7 +97,7 @@ func Test_WithWorker(t *testing.T) {
 			return len(taskTwoChan) > 2
 		}, 50*time.Millisecond, 5*time.Millisecond)
 
-		withWorker.Stop(context.Background(), slog.Default())
+		require.NoError(t, withWorker.Stop(context.Background(), slog.Default()))
 
 		taskOneChanLenght := len(taskOneChan)
 		taskTwoChanLenght := len(taskTwoChan)
@@ -106,4 +111,74 @@ func Test_WithWorker(t *testing.T) {
 		}, 50*time.Millisecond, 5*time.Millisecond)
 
 	})
+
+	t.Run("task names", func(t *testing.T) {
+		withWorker := NewWithWorker()
+		withWorker.Register(testWorkerTask)
+
+		require.Equal(t, []string{"github.com/tscolari/servicetools/server.testWorkerTask"}, withWorker.TaskNames())
+
+		withWorker.RegisterNamed("cleanup", testWorkerTask)
+		require.Equal(t, []string{"github.com/tscolari/servicetools/server.testWorkerTask", "cleanup"}, withWorker.TaskNames())
+
+		require.Panics(t, func() {
+			withWorker.RegisterNamed("cleanup", testWorkerTask)
+		})
+	})
+
+	t.Run("running a single task", func(t *testing.T) {
+		withWorker := NewWithWorker()
+
+		otherCalled := false
+		withWorker.RegisterNamed("other", func(ctx context.Context, logger *slog.Logger) error {
+			otherCalled = true
+			return nil
+		})
+		withWorker.RegisterNamed("failing", func(ctx context.Context, logger *slog.Logger) error {
+			return errors.New("boom")
+		})
+		withWorker.RegisterNamed("blocking", testWorkerTask)
+
+		err := withWorker.RunTask(context.Background(), slog.Default(), "failing")
+		require.ErrorContains(t, err, `worker task "failing" failed: boom`)
+		require.False(t, otherCalled)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		require.NoError(t, withWorker.RunTask(ctx, slog.Default(), "blocking"))
+
+		err = withWorker.RunTask(context.Background(), slog.Default(), "missing")
+		require.ErrorIs(t, err, ErrUnknownTask)
+	})
+
+	t.Run("tasks are traced", func(t *testing.T) {
+		exporter := tracing.NewMemoryExporter()
+		tracing.SetExporter(exporter)
+		defer tracing.SetExporter(nil)
+
+		out := &bytes.Buffer{}
+		logger := slog.New(slog.NewTextHandler(out, nil))
+
+		withWorker := NewWithWorker()
+		withWorker.RegisterNamed("traced", func(ctx context.Context, logger *slog.Logger) error {
+			_, ok := tracing.SpanFromContext(ctx)
+			require.True(t, ok)
+
+			logger.Info("from task")
+			return errors.New("failed")
+		})
+
+		require.Error(t, withWorker.RunTask(context.Background(), logger, "traced"))
+
+		spans := exporter.Spans()
+		require.Len(t, spans, 1)
+		require.Equal(t, "worker traced", spans[0].Name)
+		require.Equal(t, "failed", spans[0].Error)
+		require.Contains(t, out.String(), "trace_id="+spans[0].TraceID)
+	})
+}
+
+func testWorkerTask(ctx context.Context, logger *slog.Logger) error {
+	<-ctx.Done()
+	return nil
 }
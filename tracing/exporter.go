@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tscolari/servicetools/logging"
+)
+
+// SpanData is a finished span, as given to exporters.
+type SpanData struct {
+	Name         string         `json:"name"`
+	Kind         Kind           `json:"kind"`
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	TraceState   string         `json:"trace_state,omitempty"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// Duration returns how long the span took.
+func (d SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// Exporter receives the spans when they end, e.g. to send them to a tracing backend.
+// Export is called synchronously, from the goroutine ending the span, so it
+// must be safe for concurrent use and shouldn't block.
+type Exporter interface {
+	Export(span SpanData) error
+}
+
+type exporterHolder struct {
+	exporter Exporter
+}
+
+var currentExporter atomic.Pointer[exporterHolder]
+
+// SetExporter sets the exporter of all spans, replacing the previous one.
+// With a nil exporter, which is the default, spans are still propagated but not exported.
+func SetExporter(exporter Exporter) {
+	currentExporter.Store(&exporterHolder{exporter: exporter})
+}
+
+func export(span SpanData) {
+	holder := currentExporter.Load()
+	if holder == nil || holder.exporter == nil {
+		return
+	}
+
+	if err := holder.exporter.Export(span); err != nil {
+		logging.Default().Warn("failed to export span", "span", span.Name, "error", err)
+	}
+}
+
+// NewFileExporter returns an exporter that appends the spans, as JSON lines,
+// to the file in the given path, which is created if needed.
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spans file: %w", err)
+	}
+
+	return &FileExporter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// FileExporter writes spans as JSON lines to a file.
+type FileExporter struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+var _ Exporter = &FileExporter{}
+
+// Export writes the span as a JSON line.
+func (e *FileExporter) Export(span SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.encoder.Encode(span)
+}
+
+// Close closes the file.
+func (e *FileExporter) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.file.Close()
+}
+
+// NewMemoryExporter returns an exporter that keeps the spans in memory, e.g. for tests.
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// MemoryExporter keeps the exported spans in memory.
+type MemoryExporter struct {
+	mutex sync.Mutex
+	spans []SpanData
+}
+
+var _ Exporter = &MemoryExporter{}
+
+// Export keeps the span.
+func (e *MemoryExporter) Export(span SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.spans = append(e.spans, span)
+	return nil
+}
+
+// Spans returns the exported spans, in the order they ended.
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset drops the exported spans.
+func (e *MemoryExporter) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.spans = nil
+}
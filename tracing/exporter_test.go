@@ -0,0 +1,50 @@
+package tracing_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tscolari/servicetools/tracing"
+)
+
+func Test_FileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+
+	exporter, err := tracing.NewFileExporter(path)
+	require.NoError(t, err)
+
+	tracing.SetExporter(exporter)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+
+	for _, name := range []string{"one", "two"} {
+		_, span := tracing.Start(context.Background(), name, tracing.KindInternal)
+		span.SetAttribute("name", name)
+		span.End()
+	}
+
+	require.NoError(t, exporter.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var spans []tracing.SpanData
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var span tracing.SpanData
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+
+	require.Len(t, spans, 2)
+	require.Equal(t, "one", spans[0].Name)
+	require.Equal(t, map[string]any{"name": "one"}, spans[0].Attributes)
+	require.Equal(t, "two", spans[1].Name)
+	require.Len(t, spans[1].TraceID, 32)
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Handler creates a server span around every request, as a child of the trace context
+// in the request headers, if any. The span is named after the method and the route
+// matched by an http.ServeMux (or the path, if none), e.g. "GET /users/{id}".
+// The handler gets the span, and a logger annotated with it, from the request context.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := Extract(r.Context(),
+			r.Header.Get(TraceparentHeader),
+			strings.Join(r.Header.Values(TracestateHeader), ","),
+		)
+
+		ctx, span := Start(ctx, r.Method+" "+r.URL.Path, KindServer)
+		defer span.End()
+
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.path", r.URL.Path)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(recorder, r)
+
+		// The route is only known after the request was routed by the mux.
+		if r.Pattern != "" {
+			span.SetName(r.Method + " " + strings.TrimPrefix(r.Pattern, r.Method+" "))
+			span.SetAttribute("http.route", r.Pattern)
+		}
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		span.SetAttribute("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+		}
+	})
+}
+
+// statusRecorder records the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses working.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the original ResponseWriter.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Transport returns an http.RoundTripper that creates a client span around every
+// request, ending when the response headers are received, and propagates it in
+// the request headers.
+// If base is nil, http.DefaultTransport is used.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		ctx, span := Start(r.Context(), "HTTP "+r.Method, KindClient)
+		defer span.End()
+
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.url", r.URL.Redacted())
+
+		// RoundTrippers must not modify the given request.
+		r = r.Clone(ctx)
+		sc := span.SpanContext()
+		r.Header.Set(TraceparentHeader, sc.Traceparent())
+		if sc.TraceState != "" {
+			r.Header.Set(TracestateHeader, sc.TraceState)
+		}
+
+		resp, err := base.RoundTrip(r)
+		if err != nil {
+			span.SetError(err)
+			return nil, err
+		}
+
+		span.SetAttribute("http.status_code", resp.StatusCode)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetError(errors.New(resp.Status))
+		}
+
+		return resp, nil
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
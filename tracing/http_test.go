@@ -0,0 +1,56 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tscolari/servicetools/tracing"
+)
+
+func Test_HTTP(t *testing.T) {
+	exporter := useMemoryExporter(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, ok := tracing.SpanFromContext(r.Context())
+		require.True(t, ok)
+
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	server := httptest.NewServer(tracing.Handler(mux))
+	defer server.Close()
+
+	client := &http.Client{Transport: tracing.Transport(nil)}
+
+	ctx, root := tracing.Start(context.Background(), "root", tracing.KindInternal)
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/users/1", nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(r)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	root.End()
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 3)
+
+	serverSpan, clientSpan, rootSpan := spans[0], spans[1], spans[2]
+
+	require.Equal(t, "GET /users/{id}", serverSpan.Name)
+	require.Equal(t, tracing.KindServer, serverSpan.Kind)
+	require.Equal(t, 500, serverSpan.Attributes["http.status_code"])
+	require.Equal(t, "500 Internal Server Error", serverSpan.Error)
+
+	require.Equal(t, "HTTP GET", clientSpan.Name)
+	require.Equal(t, tracing.KindClient, clientSpan.Kind)
+
+	require.Equal(t, rootSpan.TraceID, clientSpan.TraceID)
+	require.Equal(t, rootSpan.TraceID, serverSpan.TraceID)
+	require.Equal(t, rootSpan.SpanID, clientSpan.ParentSpanID)
+	require.Equal(t, clientSpan.SpanID, serverSpan.ParentSpanID)
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Names of the HTTP headers, and gRPC metadata keys, that carry the trace context.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+const (
+	// traceparentLength is the length of a version 00 traceparent,
+	// e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+	traceparentLength = 55
+
+	// maxTracestateLength is the maximum length of a tracestate that is propagated.
+	maxTracestateLength = 512
+)
+
+// ErrInvalidTraceparent is returned when a traceparent can't be parsed.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses the value of a `traceparent` header, along with the
+// one of the `tracestate` header, which can be empty.
+// A tracestate over 512 characters is dropped, as allowed by the recommendation.
+func ParseTraceparent(traceparent, tracestate string) (SpanContext, error) {
+	var sc SpanContext
+
+	if len(traceparent) < traceparentLength || (len(traceparent) > traceparentLength && traceparent[traceparentLength] != '-') {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, traceparent)
+	}
+
+	version, rest, _ := strings.Cut(traceparent, "-")
+	traceID, rest, _ := strings.Cut(rest, "-")
+	spanID, rest, _ := strings.Cut(rest, "-")
+	flags, _, _ := strings.Cut(rest, "-")
+
+	// Future versions may add fields, but version 00 has exactly these ones.
+	if version == "ff" || (version == "00" && len(traceparent) != traceparentLength) {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceparent, traceparent)
+	}
+
+	var versionByte, flagsByte [1]byte
+	if err := decodeHex(versionByte[:], version); err != nil {
+		return sc, err
+	}
+
+	if err := decodeHex(sc.TraceID[:], traceID); err != nil {
+		return sc, err
+	}
+
+	if err := decodeHex(sc.SpanID[:], spanID); err != nil {
+		return sc, err
+	}
+
+	if err := decodeHex(flagsByte[:], flags); err != nil {
+		return sc, err
+	}
+
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("%w: %q has an all-zero ID", ErrInvalidTraceparent, traceparent)
+	}
+
+	sc.Flags = flagsByte[0]
+
+	if tracestate = strings.TrimSpace(tracestate); len(tracestate) <= maxTracestateLength {
+		sc.TraceState = tracestate
+	}
+
+	return sc, nil
+}
+
+// decodeHex decodes lowercase hex into dst, which it must fill exactly.
+func decodeHex(dst []byte, value string) error {
+	if len(value) != hex.EncodedLen(len(dst)) || strings.ToLower(value) != value {
+		return fmt.Errorf("%w: bad field %q", ErrInvalidTraceparent, value)
+	}
+
+	if _, err := hex.Decode(dst, []byte(value)); err != nil {
+		return fmt.Errorf("%w: bad field %q", ErrInvalidTraceparent, value)
+	}
+
+	return nil
+}
+
+// Traceparent returns the value of the `traceparent` header for the span context.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// Extract returns a context with the remote parent given by the values of the
+// `traceparent` and `tracestate` headers (see ContextWithRemoteParent).
+// Invalid values are ignored, so that a new trace is started.
+func Extract(ctx context.Context, traceparent, tracestate string) context.Context {
+	sc, err := ParseTraceparent(traceparent, tracestate)
+	if err != nil {
+		return ctx
+	}
+
+	return ContextWithRemoteParent(ctx, sc)
+}
+
+// Inject returns the values of the `traceparent` and `tracestate` headers to propagate
+// the trace context in the context (see SpanContextFromContext), if any.
+func Inject(ctx context.Context) (traceparent, tracestate string, ok bool) {
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok {
+		return "", "", false
+	}
+
+	return sc.Traceparent(), sc.TraceState, true
+}
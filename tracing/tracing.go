@@ -0,0 +1,283 @@
+// Package tracing implements basic distributed tracing, following the W3C Trace Context
+// recommendation (https://www.w3.org/TR/trace-context/), without external dependencies.
+// Spans are created with Start, and exported when they end to the Exporter set with
+// SetExporter. The trace context is propagated in the `traceparent` and `tracestate`
+// HTTP headers (see Handler and Transport) and gRPC metadata (see the server/grpc package).
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/tscolari/servicetools/logging"
+)
+
+// Fields of the logger annotated by Start.
+const (
+	LoggerFieldTraceID = "trace_id"
+	LoggerFieldSpanID  = "span_id"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the ID in lowercase hex.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid returns false for the all-zero ID.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a span in a trace.
+type SpanID [8]byte
+
+// String returns the ID in lowercase hex.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid returns false for the all-zero ID.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// flagSampled is the trace flag set when the trace is being recorded.
+const flagSampled = 0x01
+
+// SpanContext is the part of a span that is propagated to other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+
+	// TraceState is vendor-specific trace information, propagated as is.
+	TraceState string
+}
+
+// IsValid returns true if both IDs are valid.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled returns true if the trace is being recorded, so its spans are exported.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// Kind describes the relationship between a span and its parent.
+type Kind string
+
+const (
+	// KindInternal is an operation inside of a service, e.g. a worker task.
+	KindInternal Kind = "internal"
+
+	// KindServer is the handling of a request from a client.
+	KindServer Kind = "server"
+
+	// KindClient is a request to another service, e.g. a database.
+	KindClient Kind = "client"
+)
+
+// Span is an operation in a trace.
+// Its methods are safe for concurrent use.
+type Span struct {
+	mutex sync.Mutex
+
+	name         string
+	kind         Kind
+	spanContext  SpanContext
+	parentSpanID SpanID
+	start        time.Time
+	end          time.Time
+	attributes   map[string]any
+	err          error
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start starts a span with the given name, and returns it along with a context holding it.
+// The span is a child of the span in the given context, if any, or of the remote span
+// set by ContextWithRemoteParent. Otherwise, it starts a new trace.
+// Unless the span has a parent in this service, the logger in the context
+// (see logging.FromContext) is annotated with the trace and span IDs, so that
+// all the logs of a request can be correlated.
+// The span must be ended with End.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	span := &Span{
+		name:  name,
+		kind:  kind,
+		start: time.Now(),
+	}
+
+	parent, local := SpanFromContext(ctx)
+	switch {
+	case local:
+		span.spanContext = parent.SpanContext()
+		span.parentSpanID = span.spanContext.SpanID
+
+	default:
+		if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+			span.spanContext = remote
+			span.parentSpanID = remote.SpanID
+		} else {
+			span.spanContext = SpanContext{TraceID: newTraceID(), Flags: flagSampled}
+		}
+	}
+
+	span.spanContext.SpanID = newSpanID()
+
+	if !local {
+		logger := logging.FromContext(ctx).With(
+			LoggerFieldTraceID, span.spanContext.TraceID.String(),
+			LoggerFieldSpanID, span.spanContext.SpanID.String(),
+		)
+		ctx = logging.ToContext(ctx, logger)
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext returns the span in the context, if any.
+func SpanFromContext(ctx context.Context) (*Span, bool) {
+	span, ok := ctx.Value(spanKey{}).(*Span)
+	return span, ok
+}
+
+// SpanContextFromContext returns the span context to propagate to other services:
+// the one of the span in the context or, if there's none, the remote one.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if span, ok := SpanFromContext(ctx); ok {
+		return span.SpanContext(), true
+	}
+
+	remote, ok := ctx.Value(remoteKey{}).(SpanContext)
+	return remote, ok
+}
+
+// ContextWithRemoteParent returns a context with the span context received from
+// another service, so that the spans started with it become its children.
+// Invalid span contexts are ignored.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContext returns the span context, e.g. to propagate it to other services.
+func (s *Span) SpanContext() SpanContext {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.spanContext
+}
+
+// SetName changes the name of the span, e.g. once the route of a request is known.
+func (s *Span) SetName(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.name = name
+}
+
+// SetAttribute sets an attribute of the span, replacing any with the same key.
+func (s *Span) SetAttribute(key string, value any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.attributes == nil {
+		s.attributes = map[string]any{}
+	}
+
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed with the given error, if it's not nil.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.err = err
+}
+
+// End ends the span and, if its trace is sampled, exports it.
+// Calling End more than once has no effect.
+func (s *Span) End() {
+	s.mutex.Lock()
+	if !s.end.IsZero() {
+		s.mutex.Unlock()
+		return
+	}
+
+	s.end = time.Now()
+	data := s.data()
+	sampled := s.spanContext.IsSampled()
+	s.mutex.Unlock()
+
+	if sampled {
+		export(data)
+	}
+}
+
+func (s *Span) data() SpanData {
+	data := SpanData{
+		Name:       s.name,
+		Kind:       s.kind,
+		TraceID:    s.spanContext.TraceID.String(),
+		SpanID:     s.spanContext.SpanID.String(),
+		TraceState: s.spanContext.TraceState,
+		Start:      s.start,
+		End:        s.end,
+	}
+
+	if s.parentSpanID.IsValid() {
+		data.ParentSpanID = s.parentSpanID.String()
+	}
+
+	if len(s.attributes) > 0 {
+		data.Attributes = make(map[string]any, len(s.attributes))
+		for key, value := range s.attributes {
+			data.Attributes[key] = value
+		}
+	}
+
+	if s.err != nil {
+		data.Error = s.err.Error()
+	}
+
+	return data
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+
+	return id
+}
@@ -0,0 +1,129 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tscolari/servicetools/logging"
+	"github.com/tscolari/servicetools/tracing"
+)
+
+// useMemoryExporter exports the spans of the test to a MemoryExporter.
+func useMemoryExporter(t *testing.T) *tracing.MemoryExporter {
+	exporter := tracing.NewMemoryExporter()
+	tracing.SetExporter(exporter)
+	t.Cleanup(func() { tracing.SetExporter(nil) })
+
+	return exporter
+}
+
+func Test_Start(t *testing.T) {
+	t.Run("spans of the same trace", func(t *testing.T) {
+		exporter := useMemoryExporter(t)
+
+		out := &bytes.Buffer{}
+		ctx := logging.ToContext(context.Background(), slog.New(slog.NewTextHandler(out, nil)))
+
+		ctx, root := tracing.Start(ctx, "root", tracing.KindServer)
+		childCtx, child := tracing.Start(ctx, "child", tracing.KindClient)
+		child.SetAttribute("key", "value")
+		child.SetError(errors.New("failed"))
+		child.End()
+		child.End()
+		root.End()
+
+		spans := exporter.Spans()
+		require.Len(t, spans, 2)
+
+		require.Equal(t, "child", spans[0].Name)
+		require.Equal(t, tracing.KindClient, spans[0].Kind)
+		require.Equal(t, spans[1].TraceID, spans[0].TraceID)
+		require.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+		require.Equal(t, map[string]any{"key": "value"}, spans[0].Attributes)
+		require.Equal(t, "failed", spans[0].Error)
+
+		require.Equal(t, "root", spans[1].Name)
+		require.Empty(t, spans[1].ParentSpanID)
+		require.Empty(t, spans[1].Error)
+		require.GreaterOrEqual(t, spans[1].Duration(), spans[0].Duration())
+
+		// Only the root span annotates the logger.
+		logging.FromContext(childCtx).Info("hello")
+		require.Contains(t, out.String(), "trace_id="+spans[1].TraceID)
+		require.Contains(t, out.String(), "span_id="+spans[1].SpanID)
+		require.NotContains(t, out.String(), spans[0].SpanID)
+	})
+
+	t.Run("remote parent", func(t *testing.T) {
+		exporter := useMemoryExporter(t)
+
+		ctx := tracing.Extract(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "vendor=value")
+		ctx, span := tracing.Start(ctx, "server", tracing.KindServer)
+		span.End()
+
+		spans := exporter.Spans()
+		require.Len(t, spans, 1)
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceID)
+		require.Equal(t, "00f067aa0ba902b7", spans[0].ParentSpanID)
+		require.Equal(t, "vendor=value", spans[0].TraceState)
+
+		traceparent, tracestate, ok := tracing.Inject(ctx)
+		require.True(t, ok)
+		require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+spans[0].SpanID+"-01", traceparent)
+		require.Equal(t, "vendor=value", tracestate)
+	})
+
+	t.Run("traces that are not sampled", func(t *testing.T) {
+		exporter := useMemoryExporter(t)
+
+		ctx := tracing.Extract(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "")
+		ctx, span := tracing.Start(ctx, "server", tracing.KindServer)
+		span.End()
+
+		require.Empty(t, exporter.Spans())
+
+		// They are still propagated.
+		traceparent, _, ok := tracing.Inject(ctx)
+		require.True(t, ok)
+		require.Regexp(t, "^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-00$", traceparent)
+	})
+
+	t.Run("without exporter", func(t *testing.T) {
+		_, span := tracing.Start(context.Background(), "span", tracing.KindInternal)
+		span.End()
+	})
+}
+
+func Test_ParseTraceparent(t *testing.T) {
+	sc, err := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", " a=1,b=2 ")
+	require.NoError(t, err)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	require.True(t, sc.IsSampled())
+	require.Equal(t, "a=1,b=2", sc.TraceState)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	// Future versions can have more fields.
+	_, err = tracing.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "")
+	require.NoError(t, err)
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",
+	} {
+		_, err := tracing.ParseTraceparent(invalid, "")
+		require.ErrorIs(t, err, tracing.ErrInvalidTraceparent, invalid)
+	}
+}
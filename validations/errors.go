@@ -1,16 +1,28 @@
 package validations
 
 import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 // Error is a simple wrapper on an error that will
 // transform it into an invalid argument status error.
+// The fields that the error refers to (see FieldViolations) are
+// added to the status details, as errdetails.BadRequest.
 func Error(err error) error {
 	if err == nil {
 		return nil
 	}
 
-	return status.Errorf(codes.InvalidArgument, "invalid argument: %v", err)
+	st := status.Newf(codes.InvalidArgument, "invalid argument: %v", err)
+
+	if violations := FieldViolations(err); len(violations) > 0 {
+		withDetails, detailsErr := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+		if detailsErr == nil {
+			st = withDetails
+		}
+	}
+
+	return st.Err()
 }
@@ -0,0 +1,144 @@
+package validations
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// NewFieldError returns an error about the field with the given path, e.g. "address.city".
+// Error translates it into a field violation of the status details.
+// It returns nil if err is nil.
+func NewFieldError(field string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &fieldError{field: field, err: err}
+}
+
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e *fieldError) Error() string {
+	return e.field + ": " + e.err.Error()
+}
+
+// Field returns the path of the field.
+func (e *fieldError) Field() string {
+	return e.field
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// FieldViolations returns the field violations carried by the error, with the paths
+// of the fields. These are found in:
+//   - errors created with NewFieldError;
+//   - errors with a `Field() string` method, like the ones generated by
+//     protoc-gen-validate, along with their `Reason() string` and `Cause() error`;
+//   - maps of field names to errors, like the Errors type of ozzo-validation;
+//
+// and, recursively, in the errors that they wrap, including joined errors and
+// the ones returned by an `AllErrors() []error` method.
+func FieldViolations(err error) []*errdetails.BadRequest_FieldViolation {
+	return fieldViolations("", err)
+}
+
+func fieldViolations(path string, err error) []*errdetails.BadRequest_FieldViolation {
+	if err == nil {
+		return nil
+	}
+
+	if fieldErr, ok := err.(interface{ Field() string }); ok {
+		path = joinPath(path, fieldErr.Field())
+
+		var cause error
+		if causer, ok := err.(interface{ Cause() error }); ok {
+			cause = causer.Cause()
+		} else {
+			cause = errors.Unwrap(err)
+		}
+
+		if violations := fieldViolations(path, cause); len(violations) > 0 {
+			return violations
+		}
+
+		description := err.Error()
+		if reasoner, ok := err.(interface{ Reason() string }); ok {
+			description = reasoner.Reason()
+		} else if cause != nil {
+			description = cause.Error()
+		}
+
+		return []*errdetails.BadRequest_FieldViolation{{Field: path, Description: description}}
+	}
+
+	var children []error
+	switch e := err.(type) {
+	case interface{ AllErrors() []error }:
+		children = e.AllErrors()
+	case interface{ Unwrap() []error }:
+		children = e.Unwrap()
+	default:
+		if violations := mapViolations(path, err); violations != nil {
+			return violations
+		}
+
+		if wrapped := errors.Unwrap(err); wrapped != nil {
+			return fieldViolations(path, wrapped)
+		}
+
+		if path != "" {
+			return []*errdetails.BadRequest_FieldViolation{{Field: path, Description: err.Error()}}
+		}
+
+		return nil
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, child := range children {
+		violations = append(violations, fieldViolations(path, child)...)
+	}
+
+	return violations
+}
+
+// mapViolations returns the violations of errors that are maps of field names to errors,
+// sorted by field, or nil if the error isn't such a map.
+func mapViolations(path string, err error) []*errdetails.BadRequest_FieldViolation {
+	value := reflect.ValueOf(err)
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+
+	if value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String || !value.Type().Elem().Implements(errorType) {
+		return nil
+	}
+
+	keys := value.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	violations := []*errdetails.BadRequest_FieldViolation{}
+	for _, key := range keys {
+		fieldErr, _ := value.MapIndex(key).Interface().(error)
+		violations = append(violations, fieldViolations(joinPath(path, key.String()), fieldErr)...)
+	}
+
+	return violations
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+
+	if field == "" {
+		return path
+	}
+
+	return path + "." + field
+}
@@ -0,0 +1,123 @@
+package validations_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tscolari/servicetools/validations"
+)
+
+// mapErrors has the same shape as the Errors type of ozzo-validation.
+type mapErrors map[string]error
+
+func (e mapErrors) Error() string {
+	return fmt.Sprintf("%d invalid fields", len(e))
+}
+
+// generatedError has the same methods as the errors generated by protoc-gen-validate.
+type generatedError struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e generatedError) Error() string  { return "invalid " + e.field + ": " + e.reason }
+func (e generatedError) Field() string  { return e.field }
+func (e generatedError) Reason() string { return e.reason }
+func (e generatedError) Cause() error   { return e.cause }
+
+type generatedMultiError []error
+
+func (e generatedMultiError) Error() string      { return "multiple errors" }
+func (e generatedMultiError) AllErrors() []error { return e }
+
+func Test_FieldViolations(t *testing.T) {
+	violation := func(field, description string) *errdetails.BadRequest_FieldViolation {
+		return &errdetails.BadRequest_FieldViolation{Field: field, Description: description}
+	}
+
+	testCases := []struct {
+		name       string
+		err        error
+		violations []*errdetails.BadRequest_FieldViolation
+	}{
+		{
+			name: "without fields",
+			err:  errors.New("invalid"),
+		},
+		{
+			name:       "field error",
+			err:        validations.NewFieldError("name", errors.New("is required")),
+			violations: []*errdetails.BadRequest_FieldViolation{violation("name", "is required")},
+		},
+		{
+			name: "wrapped and joined field errors",
+			err: fmt.Errorf("request: %w", errors.Join(
+				validations.NewFieldError("name", errors.New("is required")),
+				validations.NewFieldError("address", validations.NewFieldError("city", errors.New("is too long"))),
+			)),
+			violations: []*errdetails.BadRequest_FieldViolation{
+				violation("name", "is required"),
+				violation("address.city", "is too long"),
+			},
+		},
+		{
+			name: "maps of field errors",
+			err: mapErrors{
+				"name":    errors.New("is required"),
+				"address": mapErrors{"city": errors.New("is too long")},
+			},
+			violations: []*errdetails.BadRequest_FieldViolation{
+				violation("address.city", "is too long"),
+				violation("name", "is required"),
+			},
+		},
+		{
+			name: "generated errors",
+			err: generatedMultiError{
+				generatedError{field: "Name", reason: "value is required"},
+				generatedError{field: "Address", reason: "embedded message failed validation", cause: generatedError{field: "City", reason: "value is too long"}},
+			},
+			violations: []*errdetails.BadRequest_FieldViolation{
+				violation("Name", "value is required"),
+				violation("Address.City", "value is too long"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.violations, validations.FieldViolations(tc.err))
+		})
+	}
+}
+
+func Test_Error(t *testing.T) {
+	require.NoError(t, validations.Error(nil))
+
+	t.Run("without fields", func(t *testing.T) {
+		st := status.Convert(validations.Error(errors.New("bad request")))
+		require.Equal(t, codes.InvalidArgument, st.Code())
+		require.Equal(t, "invalid argument: bad request", st.Message())
+		require.Empty(t, st.Details())
+	})
+
+	t.Run("with fields", func(t *testing.T) {
+		st := status.Convert(validations.Error(validations.NewFieldError("name", errors.New("is required"))))
+		require.Equal(t, codes.InvalidArgument, st.Code())
+		require.Equal(t, "invalid argument: name: is required", st.Message())
+
+		require.Len(t, st.Details(), 1)
+		badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+		require.True(t, ok)
+		require.Len(t, badRequest.GetFieldViolations(), 1)
+		require.Equal(t, "name", badRequest.GetFieldViolations()[0].GetField())
+		require.Equal(t, "is required", badRequest.GetFieldViolations()[0].GetDescription())
+	})
+}